@@ -0,0 +1,293 @@
+// Command larkdown queries markdown files with selector queries, and prints the decoded results.
+//
+// Usage:
+//
+//	larkdown query [flags] <selector> [file.md]
+//
+// For example, to print the first list under the Ingredients heading as JSON:
+//
+//	larkdown query '## Ingredients > .list' recipe.md
+//
+// If no file is given, or the file is "-", the markdown is read from stdin.
+//
+// Exit codes:
+//
+//	0: the query matched
+//	1: the query did not match the document
+//	2: the command or selector could not be parsed
+//	3: any other error, such as failing to read the file
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extension_ast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/text"
+	"go.abhg.dev/goldmark/hashtag"
+
+	"github.com/will-wow/larkdown"
+	"github.com/will-wow/larkdown/gmast"
+	"github.com/will-wow/larkdown/match"
+	"github.com/will-wow/larkdown/mdcallout"
+	"github.com/will-wow/larkdown/mdfield"
+	"github.com/will-wow/larkdown/mdfront"
+	"github.com/will-wow/larkdown/mdwiki"
+	"github.com/will-wow/larkdown/query"
+)
+
+// Exit codes
+const (
+	exitOK = iota
+	exitNoMatch
+	exitUsage
+	exitError
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command with the given arguments, and returns the exit code.
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "query" {
+		fmt.Fprintln(stderr, "usage: larkdown query [flags] <selector> [file.md]")
+		return exitUsage
+	}
+
+	flags := flag.NewFlagSet("query", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	format := flags.String("format", "json", "output format: json, text, or markdown")
+	all := flags.Bool("all", false, "find all matches of the last selector under the rest of the query")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: larkdown query [flags] <selector> [file.md]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args[1:]); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	switch *format {
+	case "json", "text", "markdown":
+	default:
+		fmt.Fprintf(stderr, "unknown format %q\n", *format)
+		return exitUsage
+	}
+
+	matcher, err := match.Parse(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitUsage
+	}
+
+	source, err := readSource(flags.Arg(1), stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			extension.GFM,
+			&hashtag.Extender{Variant: hashtag.ObsidianVariant},
+			&mdwiki.Extender{},
+			&mdcallout.Extender{},
+			&mdfield.Extender{},
+			&mdfront.Extender{},
+		),
+	)
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	found, err := find(doc, source, matcher, *all)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+
+		var queryErr *query.QueryError
+		if errors.As(err, &queryErr) {
+			return exitNoMatch
+		}
+		return exitError
+	}
+
+	last := matcher[len(matcher)-1]
+
+	var out bytes.Buffer
+	switch *format {
+	case "json":
+		err = writeJSON(&out, found, source, last, *all)
+	case "text":
+		err = writeText(&out, found, source, last)
+	case "markdown":
+		err = writeMarkdown(&out, found, source)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	_, _ = out.WriteTo(stdout)
+	return exitOK
+}
+
+// readSource reads a markdown file, or stdin for an empty path or "-".
+func readSource(path string, stdin io.Reader) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// find runs the query, returning a single node, or all matches of the last selector.
+func find(doc ast.Node, source []byte, matcher []match.Node, all bool) ([]ast.Node, error) {
+	if all {
+		found, err := query.QueryAll(doc, source, matcher[:len(matcher)-1], matcher[len(matcher)-1])
+		if err != nil {
+			return nil, err
+		}
+		// Finding nothing is a failed match, like it is for a single match.
+		if len(found) == 0 {
+			return nil, &query.QueryError{Matches: matcher[:len(matcher)-1], FailedMatch: matcher[len(matcher)-1]}
+		}
+		return found, nil
+	}
+
+	found, err := query.QueryOne(doc, source, matcher)
+	if err != nil {
+		return nil, err
+	}
+	return []ast.Node{found}, nil
+}
+
+// decode turns a node into data, using the decoder that fits the node.
+// Frontmatter is decoded at the key of the last selector, like [frontmatter:author.name].
+func decode(node ast.Node, source []byte, last match.Node) (any, error) {
+	switch n := node.(type) {
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		return larkdown.DecodeCode(node, source)
+	case *mdfront.Node:
+		key := ""
+		if frontmatter, ok := last.(match.Frontmatter); ok {
+			key = frontmatter.Key
+		}
+		value, ok := n.Lookup(key)
+		if !ok {
+			return nil, fmt.Errorf("frontmatter has no key %q", key)
+		}
+		return value, nil
+	case *ast.List:
+		return larkdown.DecodeListItems(node, source)
+	case *extension_ast.Table:
+		return larkdown.DecodeTableToMap(node, source)
+	case *hashtag.Node:
+		return larkdown.DecodeTag(node, source)
+	case *mdwiki.Node:
+		return larkdown.DecodeWikiLink(node, source)
+	default:
+		return larkdown.DecodeText(node, source)
+	}
+}
+
+// writeJSON writes the decoded matches as JSON. Queries with --all are written as an array.
+func writeJSON(w io.Writer, found []ast.Node, source []byte, last match.Node, all bool) error {
+	decoded := make([]any, len(found))
+	for i, node := range found {
+		value, err := decode(node, source, last)
+		if err != nil {
+			return err
+		}
+		decoded[i] = value
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if all {
+		return encoder.Encode(decoded)
+	}
+	return encoder.Encode(decoded[0])
+}
+
+// writeText writes the decoded matches as plain text, with list items and table rows on their own lines.
+func writeText(w io.Writer, found []ast.Node, source []byte, last match.Node) error {
+	for _, node := range found {
+		value, err := decode(node, source, last)
+		if err != nil {
+			return err
+		}
+
+		switch v := value.(type) {
+		case []string:
+			for _, item := range v {
+				fmt.Fprintln(w, item)
+			}
+		case []map[string]string:
+			writeTableText(w, node, source, v)
+		case larkdown.WikiLink:
+			fmt.Fprintln(w, v.Target)
+		default:
+			fmt.Fprintln(w, v)
+		}
+	}
+	return nil
+}
+
+// writeTableText writes table rows as tab-separated values, in column order.
+func writeTableText(w io.Writer, node ast.Node, source []byte, rows []map[string]string) {
+	headers := []string{}
+	gmast.ForEachChild(node.FirstChild(), source, func(cell ast.Node, index int) {
+		header := string(cell.Text(source))
+		if header == "" {
+			header = fmt.Sprint(index)
+		}
+		headers = append(headers, header)
+	})
+
+	fmt.Fprintln(w, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		cells := make([]string, len(headers))
+		for i, header := range headers {
+			cells[i] = row[header]
+		}
+		fmt.Fprintln(w, strings.Join(cells, "\t"))
+	}
+}
+
+// writeMarkdown renders the matches back to markdown.
+// Headings are rendered along with the rest of their branch.
+func writeMarkdown(w io.Writer, found []ast.Node, source []byte) error {
+	renderer := larkdown.NewNodeRenderer()
+
+	for _, node := range found {
+		last := node
+		if _, ok := node.(*ast.Heading); ok {
+			var err error
+			last, err = gmast.LastChildOfHeading(node)
+			if err != nil {
+				return err
+			}
+		}
+
+		for n := node; n != nil; n = n.NextSibling() {
+			if err := renderer.Render(w, source, n); err != nil {
+				return err
+			}
+			if n == last {
+				break
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var recipe = `---
+title: My Recipe
+servings: 4
+---
+# My Recipe
+
+## Tags
+
+#dinner #chicken
+
+## Ingredients
+
+- Chicken
+- Salt
+
+## Comments
+
+| Name  | Comment    |
+| ----- | ---------- |
+| Alice | It's good! |
+
+## Related
+
+See [[Roast Chicken|the roast]].
+
+## Steps
+
+` + "```sh\necho roast\n```" + `
+`
+
+func runQuery(t *testing.T, args ...string) (code int, stdout string, stderr string) {
+	t.Helper()
+
+	var out, errOut bytes.Buffer
+	code = run(append([]string{"query"}, args...), strings.NewReader(recipe), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestQuery(t *testing.T) {
+	t.Run("json list", func(t *testing.T) {
+		code, out, _ := runQuery(t, "## Ingredients > .list")
+		require.Equal(t, exitOK, code)
+		require.JSONEq(t, `["Chicken", "Salt"]`, out)
+	})
+
+	t.Run("json table", func(t *testing.T) {
+		code, out, _ := runQuery(t, "## Comments > .table", "-")
+		require.Equal(t, exitOK, code)
+		require.JSONEq(t, `[{"Name": "Alice", "Comment": "It's good!"}]`, out)
+	})
+
+	t.Run("json all tags", func(t *testing.T) {
+		code, out, _ := runQuery(t, "-all", "## Tags > [#tag]")
+		require.Equal(t, exitOK, code)
+		require.JSONEq(t, `["dinner", "chicken"]`, out)
+	})
+
+	t.Run("json wikilinks", func(t *testing.T) {
+		code, out, _ := runQuery(t, "-all", "## Related > [wikilink]")
+		require.Equal(t, exitOK, code)
+		require.JSONEq(t, `[{"Target": "Roast Chicken", "Alias": "the roast", "Heading": "", "Embed": false}]`, out)
+	})
+
+	t.Run("json code", func(t *testing.T) {
+		code, out, _ := runQuery(t, "## Steps > .code(sh)")
+		require.Equal(t, exitOK, code)
+		require.JSONEq(t, `"echo roast\n"`, out)
+	})
+
+	t.Run("json frontmatter", func(t *testing.T) {
+		code, out, _ := runQuery(t, "[frontmatter:servings]")
+		require.Equal(t, exitOK, code)
+		require.JSONEq(t, `4`, out)
+	})
+
+	t.Run("text frontmatter", func(t *testing.T) {
+		code, out, _ := runQuery(t, "-all", "-format", "text", "[frontmatter:title]")
+		require.Equal(t, exitOK, code)
+		require.Equal(t, "My Recipe\n", out)
+	})
+
+	t.Run("text", func(t *testing.T) {
+		code, out, _ := runQuery(t, "-format", "text", "## Comments > .table")
+		require.Equal(t, exitOK, code)
+		require.Equal(t, "Name\tComment\nAlice\tIt's good!\n", out)
+	})
+
+	t.Run("markdown branch", func(t *testing.T) {
+		code, out, _ := runQuery(t, "-format", "markdown", "## Ingredients")
+		require.Equal(t, exitOK, code)
+		require.Equal(t, "## Ingredients\n\n- Chicken\n- Salt\n\n", out)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		var out bytes.Buffer
+		code := run([]string{"query", "## Ingredients > ### Buy > .list", "../../examples/recipe.md"}, nil, &out, &out)
+		require.Equal(t, exitOK, code)
+		require.Contains(t, out.String(), "1 Medium Apple")
+	})
+}
+
+func TestQueryErrors(t *testing.T) {
+	t.Run("no match", func(t *testing.T) {
+		code, _, errOut := runQuery(t, "## Missing")
+		require.Equal(t, exitNoMatch, code)
+		require.Contains(t, errOut, "failed to match query")
+	})
+
+	t.Run("no match with all", func(t *testing.T) {
+		code, _, errOut := runQuery(t, "-all", "## Tags > .list")
+		require.Equal(t, exitNoMatch, code)
+		require.Contains(t, errOut, "failed to match query")
+	})
+
+	t.Run("bad selector", func(t *testing.T) {
+		code, _, errOut := runQuery(t, "## Ingredients > .lisst")
+		require.Equal(t, exitUsage, code)
+		require.Contains(t, errOut, "column 18")
+	})
+
+	t.Run("bad format", func(t *testing.T) {
+		code, _, _ := runQuery(t, "-format", "yaml", ".list")
+		require.Equal(t, exitUsage, code)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		code, _, _ := runQuery(t, ".list", "not-a-file.md")
+		require.Equal(t, exitError, code)
+	})
+
+	t.Run("no command", func(t *testing.T) {
+		var out bytes.Buffer
+		code := run([]string{}, nil, &out, &out)
+		require.Equal(t, exitUsage, code)
+	})
+}
@@ -1,17 +1,26 @@
 package larkdown
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"strconv"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	"github.com/yuin/goldmark/ast"
 	extension_ast "github.com/yuin/goldmark/extension/ast"
 	"go.abhg.dev/goldmark/hashtag"
+	"gopkg.in/yaml.v3"
 
 	"github.com/will-wow/larkdown/gmast"
+	"github.com/will-wow/larkdown/mdfield"
+	"github.com/will-wow/larkdown/mdfront"
+	"github.com/will-wow/larkdown/mdwiki"
 )
 
-// Decode an ast.List into a slice of strings for each item
+// Decode an ast.List into a slice of strings for each item.
+// The text of nested lists is included in their parent item, so use DecodeListTree to keep them separate.
 func DecodeListItems(node ast.Node, source []byte) (out []string, err error) {
 	list, ok := node.(*ast.List)
 	if !ok {
@@ -83,3 +92,241 @@ func DecodeTableToMap(node ast.Node, source []byte) ([]map[string]string, error)
 
 	return rows, nil
 }
+
+// Task is a decoded task list item.
+type Task struct {
+	Text     string
+	Checked  bool
+	Children []Task
+}
+
+// DecodeTasks decodes a task list into a slice of tasks, with nested lists as children.
+// Items without a checkbox are decoded as unchecked tasks.
+func DecodeTasks(node ast.Node, source []byte) ([]Task, error) {
+	list, ok := node.(*ast.List)
+	if !ok {
+		return nil, fmt.Errorf("expected list node")
+	}
+
+	tasks := []Task{}
+	var err error
+	gmast.ForEachListItem(list, source, func(item ast.Node, _ int) {
+		if err != nil {
+			return
+		}
+
+		var task Task
+		task, err = DecodeTask(item, source)
+		tasks = append(tasks, task)
+	})
+
+	return tasks, err
+}
+
+// DecodeTask decodes a single task list item, such as one found with match.TaskItem.
+func DecodeTask(node ast.Node, source []byte) (Task, error) {
+	if node.Kind() != ast.KindListItem {
+		return Task{}, fmt.Errorf("expected list item node")
+	}
+
+	task := Task{Children: []Task{}}
+	if checkBox := gmast.TaskCheckBox(node); checkBox != nil {
+		task.Checked = checkBox.IsChecked
+	}
+
+	lines := []string{}
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		// Nested lists are children, rather than part of the text.
+		if child.Kind() == ast.KindList {
+			children, err := DecodeTasks(child, source)
+			if err != nil {
+				return task, err
+			}
+			task.Children = append(task.Children, children...)
+			continue
+		}
+
+		lines = append(lines, string(child.Text(source)))
+	}
+	task.Text = strings.Join(lines, "\n")
+
+	return task, nil
+}
+
+// DecodeDefinitionList decodes a definition list parsed by goldmark's extension.DefinitionList
+// into a map of each term to the text of its descriptions.
+// Terms that are listed together share the descriptions that follow them.
+func DecodeDefinitionList(node ast.Node, source []byte) (map[string][]string, error) {
+	if node.Kind() != extension_ast.KindDefinitionList {
+		return nil, fmt.Errorf("expected definition list node, got %s", node.Kind())
+	}
+
+	definitions := map[string][]string{}
+	// The terms waiting for the descriptions that follow them.
+	terms := []string{}
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		switch child.Kind() {
+		case extension_ast.KindDefinitionTerm:
+			if prev := child.PreviousSibling(); prev != nil && prev.Kind() == extension_ast.KindDefinitionDescription {
+				terms = []string{}
+			}
+			term := string(child.Text(source))
+			terms = append(terms, term)
+			if _, ok := definitions[term]; !ok {
+				definitions[term] = []string{}
+			}
+		case extension_ast.KindDefinitionDescription:
+			lines := []string{}
+			for block := child.FirstChild(); block != nil; block = block.NextSibling() {
+				lines = append(lines, string(block.Text(source)))
+			}
+			for _, term := range terms {
+				definitions[term] = append(definitions[term], strings.Join(lines, "\n"))
+			}
+		}
+	}
+
+	return definitions, nil
+}
+
+// WikiLink is a decoded Obsidian-style [[wikilink]] or ![[embed]].
+type WikiLink struct {
+	// Target is the page being linked to, or empty for a heading in the same page.
+	Target string
+	// Alias is the text shown instead of the target, or empty.
+	Alias string
+	// Heading is the heading or ^block in the target, or empty.
+	Heading string
+	// Embed is true for ![[embeds]].
+	Embed bool
+}
+
+// DecodeWikiLink decodes a wikilink parsed by mdwiki.Extender, such as one found with match.WikiLink.
+func DecodeWikiLink(node ast.Node, source []byte) (WikiLink, error) {
+	link, ok := node.(*mdwiki.Node)
+	if !ok {
+		return WikiLink{}, fmt.Errorf("expected wikilink node, got %s", node.Kind())
+	}
+
+	return WikiLink{
+		Target:  string(link.Target),
+		Alias:   string(link.Alias),
+		Heading: string(link.Heading),
+		Embed:   link.Embed,
+	}, nil
+}
+
+// DecodeFields decodes the Dataview-style inline fields parsed by mdfield.Extender inside a node
+// into a map of each key to its values, in the order they appear.
+// For a heading, such as one found with match.Branch, the fields in the heading's branch are decoded.
+func DecodeFields(node ast.Node, source []byte) (map[string][]string, error) {
+	fields := map[string][]string{}
+	walker := func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if field, ok := n.(*mdfield.Node); ok {
+			key := string(field.Key)
+			fields[key] = append(fields[key], strings.TrimSpace(string(field.Text(source))))
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	}
+
+	heading, ok := node.(*ast.Heading)
+	if !ok {
+		err := ast.Walk(node, walker)
+		return fields, err
+	}
+
+	// Walk the heading's siblings, until the end of its branch.
+	err := gmast.WalkSiblingsUntil(heading.NextSibling(), func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if gmast.IsHeadingLevelBelow(n, heading.Level) {
+			return ast.WalkStop, nil
+		}
+		return walker(n, entering)
+	})
+	return fields, err
+}
+
+// DecodeCode decodes the raw text inside a fenced or indented code block.
+func DecodeCode(node ast.Node, source []byte) (string, error) {
+	if node.Kind() != ast.KindFencedCodeBlock && node.Kind() != ast.KindCodeBlock {
+		return "", fmt.Errorf("expected code block node, got %s", node.Kind())
+	}
+
+	var buf bytes.Buffer
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		buf.Write(line.Value(source))
+	}
+
+	return buf.String(), nil
+}
+
+// DecodeCodeAs unmarshals the contents of a fenced code block into a T,
+// using the block's language to pick yaml, json, or toml.
+//
+//	config, err := larkdown.Find(doc, source, query, larkdown.DecodeCodeAs[Config])
+func DecodeCodeAs[T any](node ast.Node, source []byte) (out T, err error) {
+	err = unmarshalCode(node, source, &out)
+	return out, err
+}
+
+// unmarshalCode unmarshals a fenced code block into a pointer, based on the block's language.
+func unmarshalCode(node ast.Node, source []byte, out any) error {
+	code, ok := node.(*ast.FencedCodeBlock)
+	if !ok {
+		return fmt.Errorf("expected fenced code block node, got %s", node.Kind())
+	}
+
+	text, err := DecodeCode(code, source)
+	if err != nil {
+		return err
+	}
+
+	language := strings.ToLower(string(code.Language(source)))
+	switch language {
+	case "yaml", "yml":
+		err = yaml.Unmarshal([]byte(text), out)
+	case "json":
+		err = json.Unmarshal([]byte(text), out)
+	case "toml":
+		err = toml.Unmarshal([]byte(text), out)
+	default:
+		return fmt.Errorf("cannot decode code block with language %q", language)
+	}
+	if err != nil {
+		return fmt.Errorf("error decoding %s code block: %w", language, err)
+	}
+
+	return nil
+}
+
+// marshalCode marshals a value into the contents of a fenced code block, based on the block's language.
+func marshalCode(node ast.Node, source []byte, value any) ([]byte, error) {
+	code, ok := node.(*ast.FencedCodeBlock)
+	if !ok {
+		return nil, fmt.Errorf("expected fenced code block node, got %s", node.Kind())
+	}
+
+	var format mdfront.Format
+	language := strings.ToLower(string(code.Language(source)))
+	switch language {
+	case "yaml", "yml":
+		format = mdfront.YAML
+	case "json":
+		format = mdfront.JSON
+	case "toml":
+		format = mdfront.TOML
+	default:
+		return nil, fmt.Errorf("cannot encode code block with language %q", language)
+	}
+
+	data, err := format.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding %s code block: %w", language, err)
+	}
+	return data, nil
+}
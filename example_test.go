@@ -112,17 +112,12 @@ func Example() {
 	// Get the comments from a table
 	// ====
 
-	// Get data from the comments table
-	commentsTable, err := larkdown.Find(doc, source, tableQuery, larkdown.DecodeTableToMap)
+	// Decode the comments table rows into structs, matching columns to field names
+	comments, err := larkdown.Find(doc, source, tableQuery, larkdown.DecodeTable[Comment])
 	if err != nil {
 		panic(fmt.Errorf("error finding comments: %w", err))
 	}
-	for _, comment := range commentsTable {
-		recipe.Comments = append(recipe.Comments, Comment{
-			Name:    comment["Name"],
-			Comment: comment["Comment"],
-		})
-	}
+	recipe.Comments = comments
 
 	fmt.Println(recipe.Ingredients)
 	fmt.Println(recipe.Tags)
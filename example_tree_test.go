@@ -0,0 +1,72 @@
+package larkdown_test
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/text"
+
+	"github.com/will-wow/larkdown"
+)
+
+var treeMarkdown = `
+# Title
+
+## Subheading
+
+### Sub-subheading
+
+- a list
+- of things
+
+## Another subheading
+
+Some content
+`
+
+func ExampleToTree() {
+	source := []byte(treeMarkdown)
+	doc := goldmark.New().Parser().Parse(text.NewReader(source))
+
+	tree, err := larkdown.ToTree(doc, source)
+	if err != nil {
+		panic(fmt.Errorf("couldn't build tree: %w", err))
+	}
+
+	out, err := json.MarshalIndent(tree, "", "  ")
+	if err != nil {
+		panic(fmt.Errorf("couldn't marshal tree: %w", err))
+	}
+
+	fmt.Println(string(out))
+
+	// Output:
+	// {
+	//   "Title": [
+	//     "# Title",
+	//     {
+	//       "Subheading": [
+	//         "## Subheading",
+	//         {
+	//           "Sub-subheading": [
+	//             "### Sub-subheading",
+	//             {
+	//               "list": [
+	//                 "a list",
+	//                 "of things"
+	//               ]
+	//             }
+	//           ]
+	//         }
+	//       ]
+	//     },
+	//     {
+	//       "Another subheading": [
+	//         "## Another subheading",
+	//         "Some content"
+	//       ]
+	//     }
+	//   ]
+	// }
+}
@@ -0,0 +1,46 @@
+package larkdown_test
+
+import (
+	"fmt"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/text"
+	"go.abhg.dev/goldmark/hashtag"
+
+	"github.com/will-wow/larkdown"
+)
+
+type TaggedRecipe struct {
+	Tags        []string  `larkdown:"## Tags > [#tag],all"`
+	Ingredients []string  `larkdown:"# My Recipe > ## Ingredients > .list:first"`
+	Comments    []Comment `larkdown:"## Comments > .table"`
+}
+
+func ExampleUnmarshal() {
+	source := []byte(recipeMarkdown)
+	// Preprocess the markdown into goldmark AST
+	md := goldmark.New(
+		goldmark.WithExtensions(
+			extension.Table,
+			&hashtag.Extender{Variant: hashtag.ObsidianVariant},
+		),
+	)
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	// Find and decode each field using its larkdown tag
+	recipe := TaggedRecipe{}
+	err := larkdown.Unmarshal(doc, source, &recipe)
+	if err != nil {
+		panic(fmt.Errorf("couldn't unmarshal recipe: %w", err))
+	}
+
+	fmt.Println(recipe.Ingredients)
+	fmt.Println(recipe.Tags)
+	fmt.Println(recipe.Comments)
+
+	// Output:
+	// [Chicken Vegetables Salt Pepper]
+	// [dinner chicken]
+	// [Alice: It's good! Bob: It's bad]
+}
@@ -0,0 +1,67 @@
+package larkdown
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/yuin/goldmark/ast"
+
+	"github.com/will-wow/larkdown/gmast"
+	"github.com/will-wow/larkdown/match"
+	"github.com/will-wow/larkdown/mdfront"
+	"github.com/will-wow/larkdown/query"
+)
+
+// FindFrontmatter finds a key in the frontmatter of a document parsed with mdfront.Extender,
+// and decodes its value. The key is a dot-separated path like "author.name", or empty for the
+// whole frontmatter.
+//
+//	tags, err := larkdown.FindFrontmatter[[]string](doc, source, "tags")
+//
+// Like Find, it returns a *query.QueryError if the key is not found, unless FindAllowNoMatch is passed.
+func FindFrontmatter[T any](doc ast.Node, source []byte, key string, opts ...FindOption) (out T, err error) {
+	config := newFindConfig(opts...)
+
+	found, err := query.QueryOne(doc, source, []match.Node{match.Frontmatter{Key: key}})
+	if err != nil {
+		var queryErr *query.QueryError
+		if config.AllowNoMatch && errors.As(err, &queryErr) {
+			return out, nil
+		}
+
+		return out, err
+	}
+
+	err = decodeFrontmatter(found, key, &out)
+	return out, err
+}
+
+// decodeFrontmatter decodes a key from a frontmatter node.
+func decodeFrontmatter(node ast.Node, key string, dst any) error {
+	frontmatter, ok := node.(*mdfront.Node)
+	if !ok {
+		return fmt.Errorf("expected frontmatter node, got %s", node.Kind())
+	}
+
+	return frontmatter.DecodeKey(key, dst)
+}
+
+// encodeFrontmatter patches a value onto a key in a frontmatter node, unless it already holds that value.
+func encodeFrontmatter(node ast.Node, key string, value reflect.Value) error {
+	frontmatter, ok := node.(*mdfront.Node)
+	if !ok {
+		return fmt.Errorf("expected frontmatter node, got %s", node.Kind())
+	}
+
+	current := reflect.New(value.Type())
+	if err := frontmatter.DecodeKey(key, current.Interface()); err == nil && reflect.DeepEqual(current.Elem().Interface(), value.Interface()) {
+		return nil
+	}
+
+	if err := frontmatter.SetKey(key, value.Interface()); err != nil {
+		return err
+	}
+	gmast.MarkModified(frontmatter)
+	return nil
+}
@@ -0,0 +1,99 @@
+package larkdown_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+
+	"github.com/will-wow/larkdown"
+	"github.com/will-wow/larkdown/internal/test"
+	"github.com/will-wow/larkdown/mdfront"
+	"github.com/will-wow/larkdown/query"
+)
+
+var frontmatterMarkdown = `---
+title: My Recipe
+tags: [dinner, chicken]
+author:
+  name: Will
+  servings: 4
+---
+
+# My Recipe
+
+## Ingredients
+
+- Chicken
+- Vegetables
+`
+
+func TestFindFrontmatter(t *testing.T) {
+	doc, source := test.TreeFromMd(t, frontmatterMarkdown, goldmark.WithExtensions(&mdfront.Extender{}))
+
+	t.Run("decodes a key", func(t *testing.T) {
+		tags, err := larkdown.FindFrontmatter[[]string](doc, source, "tags")
+		require.NoError(t, err)
+		require.Equal(t, []string{"dinner", "chicken"}, tags)
+	})
+
+	t.Run("decodes a nested key", func(t *testing.T) {
+		servings, err := larkdown.FindFrontmatter[int](doc, source, "author.servings")
+		require.NoError(t, err)
+		require.Equal(t, 4, servings)
+	})
+
+	t.Run("decodes a struct", func(t *testing.T) {
+		type author struct {
+			Name string `yaml:"name"`
+		}
+
+		found, err := larkdown.FindFrontmatter[author](doc, source, "author")
+		require.NoError(t, err)
+		require.Equal(t, author{Name: "Will"}, found)
+	})
+
+	t.Run("returns a QueryError for a missing key", func(t *testing.T) {
+		_, err := larkdown.FindFrontmatter[string](doc, source, "author.email")
+
+		var queryErr *query.QueryError
+		require.True(t, errors.As(err, &queryErr), "error is a QueryError")
+		require.Contains(t, err.Error(), "[frontmatter:author.email]")
+	})
+
+	t.Run("allows no match", func(t *testing.T) {
+		email, err := larkdown.FindFrontmatter[string](doc, source, "author.email", larkdown.FindAllowNoMatch())
+		require.NoError(t, err)
+		require.Equal(t, "", email)
+	})
+
+	t.Run("decodes toml", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, "+++\ntitle = \"My Recipe\"\n+++\n\n# My Recipe\n",
+			goldmark.WithExtensions(&mdfront.Extender{}))
+
+		title, err := larkdown.FindFrontmatter[string](doc, source, "title")
+		require.NoError(t, err)
+		require.Equal(t, "My Recipe", title)
+	})
+}
+
+func TestUnmarshalFrontmatter(t *testing.T) {
+	doc, source := test.TreeFromMd(t, frontmatterMarkdown, goldmark.WithExtensions(&mdfront.Extender{}))
+
+	var recipe struct {
+		Title       string   `larkdown:"[frontmatter:title]"`
+		Tags        []string `larkdown:"[frontmatter:tags]"`
+		Author      string   `larkdown:"[frontmatter:author.name]"`
+		Email       string   `larkdown:"[frontmatter:author.email],optional"`
+		Ingredients []string `larkdown:"## Ingredients > .list"`
+	}
+
+	err := larkdown.Unmarshal(doc, source, &recipe)
+	require.NoError(t, err)
+	require.Equal(t, "My Recipe", recipe.Title)
+	require.Equal(t, []string{"dinner", "chicken"}, recipe.Tags)
+	require.Equal(t, "Will", recipe.Author)
+	require.Equal(t, "", recipe.Email)
+	require.Equal(t, []string{"Chicken", "Vegetables"}, recipe.Ingredients)
+}
@@ -2,18 +2,39 @@ package gmast
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
 
 	"github.com/yuin/goldmark/ast"
+	extension_ast "github.com/yuin/goldmark/extension/ast"
 	"github.com/yuin/goldmark/text"
 	"go.abhg.dev/goldmark/hashtag"
 )
 
+// modifiedAttribute is the attribute that marks a node as modified.
+const modifiedAttribute = "larkdown-modified"
+
+// MarkModified records that a node or its children were changed, so a lossless renderer
+// re-renders it instead of copying it from the original source.
+// The editing functions in this package mark the nodes they change,
+// so this is only needed when editing the AST directly.
+func MarkModified(node ast.Node) {
+	node.SetAttributeString(modifiedAttribute, true)
+}
+
+// IsModified reports if a node was marked as modified.
+func IsModified(node ast.Node) bool {
+	_, ok := node.AttributeString(modifiedAttribute)
+	return ok
+}
+
 // AppendChild is a chainable version of ast.Node.AppendChild.
 // Builds a new AST in a single call.
 func AppendChild[T ast.Node](parent T, children ...ast.Node) (theParent T) {
 	for _, child := range children {
 		parent.AppendChild(parent, child)
 	}
+	MarkModified(parent)
 	return parent
 }
 
@@ -33,10 +54,17 @@ func AppendHeadingChild[T ast.Node](heading T, children ...ast.Node) (theParent
 	for _, child := range children {
 		parent.InsertAfter(parent, lastChild, child)
 	}
+	MarkModified(parent)
 
 	return heading
 }
 
+// ReplaceChildren removes all the children of a node, and appends new ones in their place.
+func ReplaceChildren[T ast.Node](parent T, children ...ast.Node) (theParent T) {
+	parent.RemoveChildren(parent)
+	return AppendChild(parent, children...)
+}
+
 // NewTextSegment builds a new ast.TextSegment and appends its content to the source in a single call.
 func NewTextSegment(newText string, source []byte) (textSegment *ast.Text, newSource []byte) {
 	newSegment, newSource := NewSegment(newText, source)
@@ -87,3 +115,134 @@ func NewHashtag(tag string, source []byte) (node ast.Node, newSource []byte) {
 		),
 		newSource
 }
+
+// NewListItem builds a new list item with a single text segment, formatted to fit in the given list.
+// Tight lists get a text block, and loose lists get a paragraph.
+func NewListItem(newText string, list *ast.List, source []byte) (node ast.Node, newSource []byte) {
+	newSegment, newSource := NewSegment(newText, source)
+
+	// The offset is the width of the marker and the space after it, like "- " or "10. ".
+	offset := 2
+	if list.IsOrdered() {
+		offset = len(strconv.Itoa(list.Start)) + 2
+	}
+
+	var block ast.Node = ast.NewTextBlock()
+	if !list.IsTight {
+		block = ast.NewParagraph()
+	}
+
+	item := AppendChild(
+		ast.NewListItem(offset),
+		AppendChild(
+			block,
+			ast.NewTextSegment(newSegment),
+		))
+
+	return item, newSource
+}
+
+// SetListItemText replaces the text at the start of a list item, appending the new text to the source.
+// The rest of the item, like a task checkbox or a nested list, is kept.
+func SetListItemText(item ast.Node, newText string, source []byte) (newSource []byte, err error) {
+	if item.Kind() != ast.KindListItem {
+		return source, fmt.Errorf("expected list item node, got %s", item.Kind())
+	}
+
+	block := item.FirstChild()
+	if block == nil || (block.Kind() != ast.KindTextBlock && block.Kind() != ast.KindParagraph) {
+		// Items that don't start with text, like an empty item, get a new block for it.
+		block = ast.NewTextBlock()
+		if list, ok := item.Parent().(*ast.List); ok && !list.IsTight {
+			block = ast.NewParagraph()
+		}
+		item.InsertBefore(item, item.FirstChild(), block)
+	}
+
+	// Keep the task checkbox, which is the block's first inline.
+	var checkBox ast.Node
+	if first, ok := block.FirstChild().(*extension_ast.TaskCheckBox); ok {
+		checkBox = first
+	}
+	for child := block.FirstChild(); child != nil; {
+		next := child.NextSibling()
+		if child != checkBox {
+			block.RemoveChild(block, child)
+		}
+		child = next
+	}
+
+	var textNode ast.Node
+	textNode, source = NewTextSegment(newText, source)
+	AppendChild(block, textNode)
+	MarkModified(block)
+
+	return source, nil
+}
+
+// SetCode replaces the contents of a fenced or indented code block, appending the new code to the source.
+func SetCode(node ast.Node, code string, source []byte) (newSource []byte, err error) {
+	if node.Kind() != ast.KindFencedCodeBlock && node.Kind() != ast.KindCodeBlock {
+		return source, fmt.Errorf("expected code block node, got %s", node.Kind())
+	}
+
+	if code != "" && !strings.HasSuffix(code, "\n") {
+		code += "\n"
+	}
+
+	lines := text.NewSegments()
+	for _, line := range strings.SplitAfter(code, "\n") {
+		if line == "" {
+			continue
+		}
+		var segment text.Segment
+		segment, source = NewSegment(line, source)
+		lines.Append(segment)
+	}
+	node.SetLines(lines)
+	MarkModified(node)
+
+	return source, nil
+}
+
+// NewTableRow builds a new table row with a text segment in each cell, aligned to match the table's columns.
+func NewTableRow(cells []string, alignments []extension_ast.Alignment, source []byte) (node ast.Node, newSource []byte) {
+	row := extension_ast.NewTableRow(alignments)
+
+	for i, cellText := range cells {
+		cell := extension_ast.NewTableCell()
+		if i < len(alignments) {
+			cell.Alignment = alignments[i]
+		}
+
+		var text ast.Node
+		text, source = NewTextSegment(cellText, source)
+		row.AppendChild(row, AppendChild(cell, text))
+	}
+
+	return row, source
+}
+
+// SetTaskChecked checks or unchecks a task list item's checkbox.
+func SetTaskChecked(item ast.Node, checked bool) error {
+	checkBox := TaskCheckBox(item)
+	if checkBox == nil {
+		return fmt.Errorf("not a task list item")
+	}
+
+	checkBox.IsChecked = checked
+	MarkModified(checkBox)
+	return nil
+}
+
+// ToggleTask flips a task list item's checkbox, and returns the new checked state.
+func ToggleTask(item ast.Node) (checked bool, err error) {
+	checkBox := TaskCheckBox(item)
+	if checkBox == nil {
+		return false, fmt.Errorf("not a task list item")
+	}
+
+	checkBox.IsChecked = !checkBox.IsChecked
+	MarkModified(checkBox)
+	return checkBox.IsChecked, nil
+}
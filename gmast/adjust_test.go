@@ -7,6 +7,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extension_ast "github.com/yuin/goldmark/extension/ast"
 
 	"github.com/will-wow/larkdown"
 	"github.com/will-wow/larkdown/gmast"
@@ -60,3 +62,141 @@ func TestAppendHeadingChild(t *testing.T) {
 
 	require.Contains(t, renderedHtml.String(), "<p>P2</p>\n<p>P3</p>", "P3 is not after P2 in HTML")
 }
+
+func TestNewListItem(t *testing.T) {
+	tree, source := test.TreeFromMd(t, `
+	1. first
+	1. second
+	`)
+
+	list, ok := tree.FirstChild().(*ast.List)
+	require.True(t, ok, "first child is not a list")
+
+	// Replace the second item
+	item, source := gmast.NewListItem("new second", list, source)
+	gmast.ReplaceChildren(list, list.FirstChild(), item)
+
+	var renderedMd bytes.Buffer
+	err := larkdown.NewNodeRenderer().Render(&renderedMd, source, tree)
+	require.NoError(t, err, "error rendering back to markdown")
+
+	require.Equal(t, "1. first\n1. new second\n", renderedMd.String())
+}
+
+func TestNewListItemOffset(t *testing.T) {
+	for markdown, offset := range map[string]int{
+		"- a\n":   2,
+		"1. a\n":  3,
+		"1) a\n":  3,
+		"10. a\n": 4,
+	} {
+		tree, source := test.TreeFromMd(t, markdown)
+		list, ok := tree.FirstChild().(*ast.List)
+		require.True(t, ok, "first child is not a list")
+
+		item, _ := gmast.NewListItem("new", list, source)
+		require.Equal(t, offset, item.(*ast.ListItem).Offset, markdown)
+	}
+}
+
+func TestSetListItemText(t *testing.T) {
+	tree, source := test.TreeFromMd(t, `
+	- [x] done
+	- parent
+	  - child
+	`, goldmark.WithExtensions(extension.TaskList))
+
+	list := tree.FirstChild()
+
+	source, err := gmast.SetListItemText(list.FirstChild(), "still done", source)
+	require.NoError(t, err)
+	source, err = gmast.SetListItemText(list.LastChild(), "new parent", source)
+	require.NoError(t, err)
+
+	_, err = gmast.SetListItemText(list, "not an item", source)
+	require.Error(t, err)
+
+	var renderedMd bytes.Buffer
+	err = larkdown.NewNodeRenderer().Render(&renderedMd, source, tree)
+	require.NoError(t, err, "error rendering back to markdown")
+
+	require.Equal(t, "- [x] still done\n- new parent\n  - child\n", renderedMd.String())
+}
+
+func TestNewTableRow(t *testing.T) {
+	source := []byte("")
+
+	alignments := []extension_ast.Alignment{extension_ast.AlignLeft, extension_ast.AlignRight}
+	row, source := gmast.NewTableRow([]string{"a", "b"}, alignments, source)
+
+	require.Equal(t, extension_ast.KindTableRow, row.Kind())
+	require.Equal(t, 2, row.ChildCount())
+
+	cell, ok := row.LastChild().(*extension_ast.TableCell)
+	require.True(t, ok, "child is not a table cell")
+	require.Equal(t, extension_ast.AlignRight, cell.Alignment)
+	require.Equal(t, "b", string(cell.Text(source)))
+}
+
+func TestSetCode(t *testing.T) {
+	tree, source := test.TreeFromMd(t, "```sh\necho hi\n```\n\nSome text\n")
+
+	source, err := gmast.SetCode(tree.FirstChild(), "echo a\necho b", source)
+	require.NoError(t, err)
+	require.True(t, gmast.IsModified(tree.FirstChild()))
+
+	code, err := larkdown.DecodeCode(tree.FirstChild(), source)
+	require.NoError(t, err)
+	require.Equal(t, "echo a\necho b\n", code)
+
+	_, err = gmast.SetCode(tree.LastChild(), "text", source)
+	require.Error(t, err, "paragraphs aren't code")
+}
+
+func TestToggleTask(t *testing.T) {
+	tree, source := test.TreeFromMd(t, `
+	- [ ] todo
+	- not a task
+	`, goldmark.WithExtensions(extension.TaskList))
+
+	list := tree.FirstChild()
+
+	checked, err := gmast.ToggleTask(list.FirstChild())
+	require.NoError(t, err)
+	require.True(t, checked)
+
+	_, err = gmast.ToggleTask(list.LastChild())
+	require.Error(t, err, "plain items can't be toggled")
+
+	var renderedMd bytes.Buffer
+	err = larkdown.NewNodeRenderer().Render(&renderedMd, source, tree)
+	require.NoError(t, err, "error rendering back to markdown")
+
+	require.Equal(t, "- [x] todo\n- not a task\n", renderedMd.String())
+
+	err = gmast.SetTaskChecked(list.FirstChild(), false)
+	require.NoError(t, err)
+	require.False(t, gmast.TaskCheckBox(list.FirstChild()).IsChecked)
+}
+
+func TestMarkModified(t *testing.T) {
+	tree, source := test.TreeFromMd(t, `
+	- [ ] todo
+
+	Some text
+	`, goldmark.WithExtensions(extension.TaskList))
+
+	list := tree.FirstChild()
+	paragraph := tree.LastChild()
+	require.False(t, gmast.IsModified(paragraph))
+
+	space, source := gmast.NewSpace(source)
+	tag, _ := gmast.NewHashtag("tag", source)
+	gmast.AppendChild(paragraph, space, tag)
+	require.True(t, gmast.IsModified(paragraph), "appending marks the parent")
+
+	_, err := gmast.ToggleTask(list.FirstChild())
+	require.NoError(t, err)
+	require.True(t, gmast.IsModified(gmast.TaskCheckBox(list.FirstChild())), "toggling marks the checkbox")
+	require.False(t, gmast.IsModified(list))
+}
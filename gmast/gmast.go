@@ -3,9 +3,11 @@
 package gmast
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/yuin/goldmark/ast"
+	extension_ast "github.com/yuin/goldmark/extension/ast"
 )
 
 // ForEachListItem runs a callback on each list item in a list.
@@ -121,3 +123,114 @@ func IsHeadingLevelBelow(node ast.Node, level int) bool {
 
 	return heading.Level <= level
 }
+
+// TaskCheckBox returns the checkbox of a task list item, or nil if the node is not a task list item.
+// The checkbox is parsed by goldmark's extension.TaskList.
+func TaskCheckBox(node ast.Node) *extension_ast.TaskCheckBox {
+	if node.Kind() != ast.KindListItem {
+		return nil
+	}
+
+	// The checkbox is the first inline of the item's first block.
+	block := node.FirstChild()
+	if block == nil {
+		return nil
+	}
+
+	checkBox, _ := block.FirstChild().(*extension_ast.TaskCheckBox)
+	return checkBox
+}
+
+// InlineStart finds where an inline node starts in the source, from the start of its first text,
+// raw HTML, or autolink. It returns -1 and false for nodes without any of those, like an empty link.
+func InlineStart(node ast.Node, source []byte) (start int, ok bool) {
+	switch n := node.(type) {
+	case *ast.Text:
+		return n.Segment.Start, true
+	case *ast.RawHTML:
+		if n.Segments.Len() == 0 {
+			return -1, false
+		}
+		return n.Segments.At(0).Start, true
+	case *ast.AutoLink:
+		return autoLinkStart(n, source)
+	}
+
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		if start, ok := InlineStart(child, source); ok {
+			return start, true
+		}
+	}
+	return -1, false
+}
+
+// autoLinkStart finds the start of an autolink's label, which is the only part of it that goldmark keeps.
+// It searches for the label after the end of the inline node before it, or the start of its block.
+func autoLinkStart(n *ast.AutoLink, source []byte) (int, bool) {
+	label := n.Label(source)
+	from, ok := positionBefore(n, source)
+	if len(label) == 0 || !ok || from > len(source) {
+		return -1, false
+	}
+
+	i := bytes.Index(source[from:], label)
+	if i == -1 {
+		return -1, false
+	}
+	start := from + i
+
+	// The label must come before the next inline node.
+	if next := n.NextSibling(); next != nil {
+		if nextStart, ok := InlineStart(next, source); ok && start+len(label) > nextStart {
+			return -1, false
+		}
+	}
+	return start, true
+}
+
+// positionBefore finds a position in the source at or before the start of an inline node:
+// the end of the inline node before it, or the start of the block it is in.
+func positionBefore(node ast.Node, source []byte) (int, bool) {
+	for n := node; n != nil; n = n.Parent() {
+		for prev := n.PreviousSibling(); prev != nil; prev = prev.PreviousSibling() {
+			if stop, ok := inlineStop(prev, source); ok {
+				return stop, true
+			}
+		}
+
+		parent := n.Parent()
+		if parent != nil && parent.Type() == ast.TypeBlock {
+			if parent.Lines().Len() == 0 {
+				return -1, false
+			}
+			return parent.Lines().At(0).Start, true
+		}
+	}
+	return -1, false
+}
+
+// inlineStop finds where the last text, raw HTML, or autolink in an inline node ends in the source.
+func inlineStop(node ast.Node, source []byte) (int, bool) {
+	switch n := node.(type) {
+	case *ast.Text:
+		return n.Segment.Stop, true
+	case *ast.RawHTML:
+		if n.Segments.Len() == 0 {
+			return -1, false
+		}
+		return n.Segments.At(n.Segments.Len() - 1).Stop, true
+	case *ast.AutoLink:
+		start, ok := autoLinkStart(n, source)
+		if !ok {
+			return -1, false
+		}
+		return start + len(n.Label(source)), true
+	}
+
+	for child := node.LastChild(); child != nil; child = child.PreviousSibling() {
+		if stop, ok := inlineStop(child, source); ok {
+			return stop, true
+		}
+	}
+	return -1, false
+}
@@ -119,3 +119,42 @@ func TestLastChildOfHeading(t *testing.T) {
 
 	require.Equal(t, "Body H3", string(lastChild.Text(source)))
 }
+
+func TestInlineStart(t *testing.T) {
+	tree, source := test.TreeFromMd(t, "a *b* <http://x.com> <i>c</i> [](/empty)")
+
+	starts := map[ast.NodeKind][]int{}
+	for child := tree.FirstChild().FirstChild(); child != nil; child = child.NextSibling() {
+		if start, ok := gmast.InlineStart(child, source); ok {
+			starts[child.Kind()] = append(starts[child.Kind()], start)
+		}
+	}
+
+	require.Equal(t, []int{3}, starts[ast.KindEmphasis])
+	require.Equal(t, []int{7}, starts[ast.KindAutoLink])
+	require.Equal(t, []int{21, 25}, starts[ast.KindRawHTML])
+	require.Nil(t, starts[ast.KindLink], "empty links have no start")
+}
+
+func TestInlineStartAutoLinks(t *testing.T) {
+	tree, source := test.TreeFromMd(t, "<http://x.com> and *<http://x.com>*")
+
+	// A copy of the source doesn't share memory with the labels, but has the same positions.
+	copied := append([]byte{}, source...)
+
+	first := tree.FirstChild().FirstChild()
+	second := first.NextSibling().NextSibling().FirstChild()
+	for _, s := range [][]byte{source, copied} {
+		start, ok := gmast.InlineStart(first, s)
+		require.True(t, ok)
+		require.Equal(t, 1, start)
+
+		start, ok = gmast.InlineStart(second, s)
+		require.True(t, ok)
+		require.Equal(t, 21, start)
+	}
+
+	start, ok := gmast.InlineStart(ast.NewLink(), source)
+	require.False(t, ok)
+	require.Equal(t, -1, start)
+}
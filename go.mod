@@ -3,6 +3,7 @@ module github.com/will-wow/larkdown
 go 1.21
 
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/lithammer/dedent v1.1.0
 	github.com/stretchr/testify v1.8.4
 	github.com/yuin/goldmark v1.5.4
@@ -12,7 +13,6 @@ require (
 )
 
 require (
-	github.com/BurntSushi/toml v1.2.1 // indirect
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/forPelevin/gomoji v1.1.3 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
@@ -0,0 +1,31 @@
+// Package kinds lists the registered goldmark node kinds.
+package kinds
+
+import (
+	"github.com/yuin/goldmark/ast"
+)
+
+// All returns every registered ast.NodeKind, including custom kinds from extensions.
+// Kinds are registered sequentially by ast.NewNodeKind, so this includes every kind that has been
+// registered so far, which is every package-level kind once packages are initialized.
+//
+// goldmark doesn't export its list of kinds, so this relies on ast.NodeKind.String panicking
+// for kinds that haven't been registered. It is internal so it can change along with goldmark.
+func All() []ast.NodeKind {
+	kinds := []ast.NodeKind{}
+	for kind := ast.NodeKind(1); isRegistered(kind); kind++ {
+		kinds = append(kinds, kind)
+	}
+	return kinds
+}
+
+// isRegistered reports if a kind has been registered, since looking up the name of an unknown kind panics.
+func isRegistered(kind ast.NodeKind) (ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	_ = kind.String()
+	return true
+}
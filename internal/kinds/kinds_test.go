@@ -0,0 +1,19 @@
+package kinds_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark/ast"
+
+	"github.com/will-wow/larkdown/internal/kinds"
+	"github.com/will-wow/larkdown/mdcallout"
+)
+
+func TestAll(t *testing.T) {
+	all := kinds.All()
+
+	require.Contains(t, all, ast.KindDocument)
+	require.Contains(t, all, ast.KindText)
+	require.Contains(t, all, mdcallout.Kind, "includes kinds from extensions")
+}
@@ -1,7 +1,10 @@
 package larkdown
 
 import (
+	"bytes"
 	"errors"
+	"io"
+	"math"
 
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/renderer"
@@ -132,6 +135,43 @@ func FindAllAllowNoMatch() FindAllOption {
 }
 
 // NewNodeRenderer returns a new goldmark NodeRenderer with default config that renders nodes as Markdown.
+//
+// With mdrender.WithStrict, it also installs an mdrender.UnsupportedRenderer, and only writes the output
+// once the whole node has rendered without an error.
 func NewNodeRenderer(opts ...mdrender.Option) renderer.Renderer {
-	return renderer.NewRenderer(renderer.WithNodeRenderers(util.Prioritized(mdrender.NewRenderer(opts...), 998)))
+	config := mdrender.NewConfig()
+	for _, opt := range opts {
+		opt.SetMarkdownOption(&config)
+	}
+
+	nodeRenderers := []util.PrioritizedValue{util.Prioritized(mdrender.NewRenderer(opts...), rendererPriority)}
+	if !config.Strict {
+		return renderer.NewRenderer(renderer.WithNodeRenderers(nodeRenderers...))
+	}
+
+	nodeRenderers = append(nodeRenderers, util.Prioritized(&mdrender.UnsupportedRenderer{}, unsupportedPriority))
+	return &bufferedRenderer{Renderer: renderer.NewRenderer(renderer.WithNodeRenderers(nodeRenderers...))}
+}
+
+// unsupportedPriority is the lowest priority, so every other renderer replaces the unsupported node
+// functions for the kinds it can render.
+const unsupportedPriority = math.MaxInt32
+
+// bufferedRenderer renders into a buffer, so nothing is written if rendering fails partway through.
+type bufferedRenderer struct {
+	renderer.Renderer
+}
+
+func (r *bufferedRenderer) Render(w io.Writer, source []byte, n ast.Node) error {
+	var buf bytes.Buffer
+	if err := r.Renderer.Render(&buf, source, n); err != nil {
+		return err
+	}
+	_, err := buf.WriteTo(w)
+	return err
 }
+
+// rendererPriority is the priority of the markdown renderer. It is higher than the priority of 500
+// that goldmark extensions use for their HTML renderers, so the markdown renderer still wins when
+// it is installed with goldmark.WithRenderer alongside extensions like extension.Table.
+const rendererPriority = 100
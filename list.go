@@ -0,0 +1,162 @@
+package larkdown
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+
+	"github.com/will-wow/larkdown/gmast"
+)
+
+// ListItem is a decoded list item, with any nested lists as children.
+type ListItem struct {
+	Text string
+	// Ordered is true for items in an ordered list.
+	Ordered  bool
+	Children []ListItem
+}
+
+// DecodeListTree decodes a list into a tree of items, with nested lists as children.
+// Unlike DecodeListItems, the text of nested items is not included in their parent's text.
+func DecodeListTree(node ast.Node, source []byte) ([]ListItem, error) {
+	list, ok := node.(*ast.List)
+	if !ok {
+		return nil, fmt.Errorf("expected list node")
+	}
+
+	items := []ListItem{}
+	gmast.ForEachListItem(list, source, func(item ast.Node, _ int) {
+		text, children := listItemParts(item, source)
+
+		decoded := ListItem{Text: text, Ordered: list.IsOrdered(), Children: []ListItem{}}
+		for _, child := range children {
+			// Errors are impossible, since the children are all lists.
+			nested, _ := DecodeListTree(child, source)
+			decoded.Children = append(decoded.Children, nested...)
+		}
+		items = append(items, decoded)
+	})
+
+	return items, nil
+}
+
+// listItemParts splits a list item into the text of its blocks, and its nested lists.
+func listItemParts(item ast.Node, source []byte) (text string, lists []ast.Node) {
+	lines := []string{}
+	for child := item.FirstChild(); child != nil; child = child.NextSibling() {
+		if child.Kind() == ast.KindList {
+			lists = append(lists, child)
+			continue
+		}
+		lines = append(lines, string(child.Text(source)))
+	}
+	return strings.Join(lines, "\n"), lists
+}
+
+// DecodeListItemsAs decodes a list of "key: value" items into a struct.
+//
+// Keys are matched to struct fields by the field's `larkdown` tag, or by its name, ignoring case.
+// Values are converted to the type of the field like DecodeTable does.
+// Slices of strings are decoded from the item's nested list, and structs from a nested list of their own keys.
+// Items without a matching field, or without a key, are ignored.
+//
+//	type Book struct {
+//		Title  string
+//		Rating int      `larkdown:"Stars"`
+//		Tags   []string
+//	}
+//
+//	book, err := larkdown.Find(doc, source, query, larkdown.DecodeListItemsAs[Book])
+//
+// Values that fail to convert are reported as *ListItemError, with the item and key.
+func DecodeListItemsAs[T any](node ast.Node, source []byte) (out T, err error) {
+	err = decodeListItemsInto(node, source, reflect.ValueOf(&out).Elem())
+	return out, err
+}
+
+// decodeListItemsInto decodes a list of "key: value" items into a struct value.
+func decodeListItemsInto(node ast.Node, source []byte, value reflect.Value) error {
+	list, ok := node.(*ast.List)
+	if !ok {
+		return fmt.Errorf("expected list node")
+	}
+	if value.Kind() != reflect.Struct {
+		return fmt.Errorf("expected a struct, got %s", value.Type())
+	}
+
+	errs := []error{}
+	gmast.ForEachListItem(list, source, func(item ast.Node, index int) {
+		text, lists := listItemParts(item, source)
+		key, itemValue, ok := strings.Cut(text, ":")
+		if !ok {
+			return
+		}
+		key = strings.TrimSpace(key)
+
+		fieldIndex := fieldForColumn(value.Type(), key)
+		if fieldIndex == -1 {
+			return
+		}
+
+		field := value.Type().Field(fieldIndex)
+		err := setListItemField(value.Field(fieldIndex), field, itemValue, lists, source)
+		if err != nil {
+			errs = append(errs, &ListItemError{
+				Item:  index + 1,
+				Key:   key,
+				Value: strings.TrimSpace(itemValue),
+				Err:   err,
+			})
+		}
+	})
+
+	return errors.Join(errs...)
+}
+
+// setListItemField sets a struct field from a list item's value, or from its nested lists.
+func setListItemField(value reflect.Value, field reflect.StructField, text string, lists []ast.Node, source []byte) error {
+	switch {
+	case value.Kind() == reflect.Slice && value.Type().Elem().Kind() == reflect.String:
+		items := reflect.MakeSlice(value.Type(), 0, 0)
+		for _, list := range lists {
+			decoded, err := DecodeListItems(list, source)
+			if err != nil {
+				return err
+			}
+			items = reflect.AppendSlice(items, reflect.ValueOf(decoded).Convert(value.Type()))
+		}
+		value.Set(items)
+		return nil
+
+	case value.Kind() == reflect.Struct && value.Type() != timeType:
+		errs := []error{}
+		for _, list := range lists {
+			errs = append(errs, decodeListItemsInto(list, source, value))
+		}
+		return errors.Join(errs...)
+	}
+
+	return setCell(value, field, text)
+}
+
+// ListItemError is returned when a list item's value can't be converted to the type of its struct field.
+type ListItemError struct {
+	// The 1-based index of the item in the list.
+	Item int
+	// The key of the item.
+	Key string
+	// The text of the value.
+	Value string
+	Err   error
+}
+
+func (e *ListItemError) Error() string {
+	return fmt.Sprintf("item %d (%s): %q %s", e.Item, e.Key, e.Value, e.Err)
+}
+
+func (e *ListItemError) Unwrap() error {
+	return e.Err
+}
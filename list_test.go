@@ -0,0 +1,97 @@
+package larkdown_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/will-wow/larkdown"
+	"github.com/will-wow/larkdown/internal/test"
+	"github.com/will-wow/larkdown/match"
+)
+
+func TestDecodeListTree(t *testing.T) {
+	doc, source := test.TreeFromFile(t, "examples/all-tags.md")
+
+	t.Run("keeps nested lists as children", func(t *testing.T) {
+		query := []match.Node{match.Branch{Level: 2, Name: []byte("Heading 2")}, match.Index{Index: 2, Node: match.List{}}}
+
+		items, err := larkdown.Find(doc, source, query, larkdown.DecodeListTree)
+		require.NoError(t, err)
+		require.Equal(t, []larkdown.ListItem{
+			{Text: "nested list", Children: []larkdown.ListItem{
+				{Text: "nested item", Children: []larkdown.ListItem{
+					{Text: "Nested ordered list", Ordered: true, Children: []larkdown.ListItem{
+						{Text: "Nested unordered list", Children: []larkdown.ListItem{}},
+					}},
+				}},
+			}},
+			{Text: "end nesting", Children: []larkdown.ListItem{}},
+		}, items)
+	})
+
+	t.Run("joins the paragraphs of loose items", func(t *testing.T) {
+		query := []match.Node{match.Branch{Level: 2, Name: []byte("Heading 2")}, match.Index{Index: 5, Node: match.List{}}}
+
+		items, err := larkdown.Find(doc, source, query, larkdown.DecodeListTree)
+		require.NoError(t, err)
+		require.Equal(t, "loose\nnumber paragraphs", items[0].Text)
+		require.Equal(t, []larkdown.ListItem{
+			{Text: "are", Ordered: true, Children: []larkdown.ListItem{}},
+			{Text: "also\nindented", Ordered: true, Children: []larkdown.ListItem{}},
+		}, items[0].Children)
+	})
+}
+
+type book struct {
+	Title   string
+	Stars   int `larkdown:"Rating"`
+	Read    bool
+	Tags    []string
+	Details struct {
+		Pages int
+	}
+}
+
+func TestDecodeListItemsAs(t *testing.T) {
+	query := []match.Node{match.List{}}
+
+	t.Run("decodes keys into fields", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+		- title: Dune: Messiah
+		- rating: 4
+		- read: yes
+		- tags:
+		  - scifi
+		  - classic
+		- details:
+		  - pages: 256
+		- publisher: Putnam
+		- no key here
+		`)
+
+		decoded, err := larkdown.Find(doc, source, query, larkdown.DecodeListItemsAs[book])
+		require.NoError(t, err)
+
+		expected := book{Title: "Dune: Messiah", Stars: 4, Read: true, Tags: []string{"scifi", "classic"}}
+		expected.Details.Pages = 256
+		require.Equal(t, expected, decoded)
+	})
+
+	t.Run("reports the position of bad values", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+		- title: Dune
+		- rating: five
+		`)
+
+		_, err := larkdown.Find(doc, source, query, larkdown.DecodeListItemsAs[book])
+		require.Error(t, err)
+
+		var itemErr *larkdown.ListItemError
+		require.True(t, errors.As(err, &itemErr))
+		require.Equal(t, 2, itemErr.Item)
+		require.Equal(t, "rating", itemErr.Key)
+		require.Equal(t, "five", itemErr.Value)
+	})
+}
@@ -0,0 +1,478 @@
+package larkdown
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	extension_ast "github.com/yuin/goldmark/extension/ast"
+	"go.abhg.dev/goldmark/hashtag"
+
+	"github.com/will-wow/larkdown/gmast"
+	"github.com/will-wow/larkdown/match"
+	"github.com/will-wow/larkdown/query"
+)
+
+// NodeMarshaler is implemented by types that can write themselves back into a matched node.
+// Marshal uses it in preference to the default encoders.
+type NodeMarshaler interface {
+	MarshalMarkdown(node ast.Node, source []byte) (newSource []byte, err error)
+}
+
+// Marshal is the reverse of Unmarshal. It uses the same `larkdown` struct tags to find each field's node
+// in an existing document, and updates that node in place so the document can be rendered back to markdown
+// with mdrender, leaving everything around the matched nodes untouched.
+//
+// Since new text is appended to the source, Marshal returns the new source to render with.
+//
+// Frontmatter queries patch the value onto the key with mdfront.Node.SetKey, keeping the frontmatter's format
+// and any other keys. Otherwise, the encoder is chosen from the field type:
+//   - NodeMarshaler: the field's MarshalMarkdown method.
+//   - string: replaces the text of a paragraph, heading, or #tag, or the contents of a code block.
+//   - []string: replaces the items of a list, keeping items that have not changed.
+//   - structs, maps, and slices in a code block: marshals the value in the yaml, json, or toml of the block.
+//   - []map[string]string and []struct: replaces the body rows of a table, keeping the header.
+//
+// Text, list items, and table rows that already hold the field's value are left as written,
+// so Marshal after Unmarshal with no edits keeps any inline formatting.
+//
+// Fields with the all option update each matched node in turn, so the slice must be the same length
+// as the matches. Fields that fail to match or encode are collected into a *MarshalError.
+func Marshal(doc ast.Node, source []byte, v any) (newSource []byte, err error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Pointer && !rv.IsNil() {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return source, fmt.Errorf("larkdown: Marshal expects a struct or pointer to a struct, got %T", v)
+	}
+	rt := rv.Type()
+
+	marshalErr := &MarshalError{}
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tagValue, ok := field.Tag.Lookup("larkdown")
+		if !ok || tagValue == "-" || !field.IsExported() {
+			continue
+		}
+
+		tag, err := parseFieldTag(tagValue)
+		if err != nil {
+			marshalErr.add(field.Name, err)
+			continue
+		}
+
+		source, err = marshalField(doc, source, rv.Field(i), tag)
+		if err != nil {
+			marshalErr.add(field.Name, err)
+		}
+	}
+
+	if len(marshalErr.Errors) > 0 {
+		return source, marshalErr
+	}
+	return source, nil
+}
+
+// marshalField finds the node for a single field, and encodes the field into it.
+func marshalField(doc ast.Node, source []byte, field reflect.Value, tag fieldTag) ([]byte, error) {
+	if tag.all {
+		return marshalAll(doc, source, field, tag)
+	}
+
+	found, err := query.QueryOne(doc, source, tag.query)
+	if err != nil {
+		var queryErr *query.QueryError
+		if tag.optional && errors.As(err, &queryErr) {
+			return source, nil
+		}
+		return source, err
+	}
+
+	// Frontmatter values are patched into the frontmatter with its own format, rather than by field type.
+	if frontmatter, ok := tag.query[len(tag.query)-1].(match.Frontmatter); ok {
+		return source, encodeFrontmatter(found, frontmatter.Key, field)
+	}
+
+	return encodeValue(found, source, field)
+}
+
+// marshalAll encodes each item of a slice into the matching node from a FindAll-style query.
+func marshalAll(doc ast.Node, source []byte, field reflect.Value, tag fieldTag) ([]byte, error) {
+	if field.Kind() != reflect.Slice {
+		return source, fmt.Errorf("the all option requires a slice field, got %s", field.Type())
+	}
+
+	matcher := tag.query[:len(tag.query)-1]
+	extractor := tag.query[len(tag.query)-1]
+
+	found, err := query.QueryAll(doc, source, matcher, extractor)
+	if err != nil {
+		var queryErr *query.QueryError
+		if tag.optional && errors.As(err, &queryErr) {
+			return source, nil
+		}
+		return source, err
+	}
+
+	if len(found) != field.Len() {
+		return source, fmt.Errorf("found %d nodes to update, but have %d items", len(found), field.Len())
+	}
+
+	for i, node := range found {
+		source, err = encodeValue(node, source, field.Index(i))
+		if err != nil {
+			return source, fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+
+	return source, nil
+}
+
+var nodeMarshalerType = reflect.TypeOf((*NodeMarshaler)(nil)).Elem()
+
+// encodeValue picks an encoder based on the type of the value, and updates the node.
+func encodeValue(node ast.Node, source []byte, value reflect.Value) ([]byte, error) {
+	if value.Type().Implements(nodeMarshalerType) {
+		marshaler, _ := value.Interface().(NodeMarshaler)
+		return marshaler.MarshalMarkdown(node, source)
+	}
+	if value.CanAddr() && value.Addr().Type().Implements(nodeMarshalerType) {
+		marshaler, _ := value.Addr().Interface().(NodeMarshaler)
+		return marshaler.MarshalMarkdown(node, source)
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return encodeString(node, source, value.String())
+
+	case reflect.Slice:
+		if node.Kind() == ast.KindFencedCodeBlock {
+			return encodeCode(node, source, value)
+		}
+		return encodeSlice(node, source, value)
+
+	case reflect.Struct, reflect.Map:
+		if node.Kind() == ast.KindFencedCodeBlock {
+			return encodeCode(node, source, value)
+		}
+	}
+
+	return source, fmt.Errorf("unsupported field type %s", value.Type())
+}
+
+// encodeCode marshals a value into a yaml, json, or toml code block, unless the block already holds that value.
+func encodeCode(node ast.Node, source []byte, value reflect.Value) ([]byte, error) {
+	current := reflect.New(value.Type())
+	if err := unmarshalCode(node, source, current.Interface()); err == nil && reflect.DeepEqual(current.Elem().Interface(), value.Interface()) {
+		return source, nil
+	}
+
+	data, err := marshalCode(node, source, value.Interface())
+	if err != nil {
+		return source, err
+	}
+	return gmast.SetCode(node, string(data), source)
+}
+
+// encodeString replaces the text of a node that holds inline content.
+// Nodes whose text has not changed are left alone, to keep any inline formatting.
+func encodeString(node ast.Node, source []byte, value string) ([]byte, error) {
+	if current, err := decodeString(node, source); err == nil && current == value {
+		return source, nil
+	}
+
+	switch n := node.(type) {
+	case *hashtag.Node:
+		text, newSource := gmast.NewTextSegment("#"+value, source)
+		n.Tag = []byte(value)
+		gmast.ReplaceChildren(n, text)
+		return newSource, nil
+
+	case *ast.Paragraph, *ast.Heading, *ast.TextBlock:
+		text, newSource := gmast.NewTextSegment(value, source)
+		gmast.ReplaceChildren(n, text)
+		return newSource, nil
+
+	case *ast.FencedCodeBlock, *ast.CodeBlock:
+		return gmast.SetCode(n, value, source)
+	}
+
+	return source, fmt.Errorf("cannot set text of %s node", node.Kind())
+}
+
+// encodeSlice updates a list from strings, or a table from maps or structs.
+func encodeSlice(node ast.Node, source []byte, value reflect.Value) ([]byte, error) {
+	elem := value.Type().Elem()
+
+	switch {
+	case elem.Kind() == reflect.String:
+		items := make([]string, value.Len())
+		for i := range items {
+			items[i] = value.Index(i).String()
+		}
+		return encodeListItems(node, source, items)
+
+	case elem == reflect.TypeOf(map[string]string{}):
+		rows, _ := value.Interface().([]map[string]string)
+		return encodeTableRows(node, source, tableEncoder{
+			rowCount: len(rows),
+			cellFor: func(row int, header string) string {
+				return rows[row][header]
+			},
+			cellEquals: func(row int, header string, text string) bool {
+				return rows[row][header] == text
+			},
+		})
+
+	case elem.Kind() == reflect.Struct:
+		return encodeTableRows(node, source, tableEncoder{
+			rowCount: value.Len(),
+			cellFor: func(row int, header string) string {
+				return structFieldForHeader(value.Index(row), header)
+			},
+			cellEquals: func(row int, header string, text string) bool {
+				return structFieldEqualsCell(value.Index(row), header, text)
+			},
+		})
+	}
+
+	return source, fmt.Errorf("unsupported field type %s", value.Type())
+}
+
+// encodeListItems replaces the items in a list, reusing existing items whose text has not changed.
+// Items are matched up by their text, so inserting or removing a value leaves the other items alone.
+// Changed items keep the rest of their contents, like a task checkbox or nested list, and only get new text.
+func encodeListItems(node ast.Node, source []byte, items []string) ([]byte, error) {
+	list, ok := node.(*ast.List)
+	if !ok {
+		return source, fmt.Errorf("expected list node, got %s", node.Kind())
+	}
+
+	existing := []ast.Node{}
+	texts := []string{}
+	gmast.ForEachListItem(list, source, func(item ast.Node, _ int) {
+		existing = append(existing, item)
+		texts = append(texts, string(item.Text(source)))
+	})
+
+	newItems := []ast.Node{}
+	// The existing items between the last two unchanged items, which changed items take the place of.
+	removed := []ast.Node{}
+	var err error
+	add := func(text string) {
+		if err != nil {
+			return
+		}
+		if len(removed) == 0 {
+			var item ast.Node
+			item, source = gmast.NewListItem(text, list, source)
+			newItems = append(newItems, item)
+			return
+		}
+
+		item := removed[0]
+		removed = removed[1:]
+		source, err = gmast.SetListItemText(item, itemText(item, text, source), source)
+		newItems = append(newItems, item)
+	}
+
+	i := 0
+	for _, pair := range matchItems(texts, items) {
+		for ; i < pair[0]; i++ {
+			removed = append(removed, existing[i])
+		}
+		for j := len(newItems); j < pair[1]; j++ {
+			add(items[j])
+		}
+		removed = nil
+		newItems = append(newItems, existing[pair[0]])
+		i = pair[0] + 1
+	}
+	for ; i < len(existing); i++ {
+		removed = append(removed, existing[i])
+	}
+	for j := len(newItems); j < len(items); j++ {
+		add(items[j])
+	}
+	if err != nil {
+		return source, err
+	}
+
+	gmast.ReplaceChildren(list, newItems...)
+
+	return source, nil
+}
+
+// itemText returns the text to write at the start of a changed list item.
+// Decoded items include the text of the rest of the item, like a nested list, so that is left off.
+func itemText(item ast.Node, text string, source []byte) string {
+	rest := ""
+	for child := item.FirstChild(); child != nil; child = child.NextSibling() {
+		if child == item.FirstChild() && (child.Kind() == ast.KindTextBlock || child.Kind() == ast.KindParagraph) {
+			continue
+		}
+		rest += string(child.Text(source))
+	}
+	if rest != "" && text != rest {
+		return strings.TrimSuffix(text, rest)
+	}
+	return text
+}
+
+// matchItems finds the longest run of values that are in both lists in the same order,
+// and returns the index in each list of every value in it.
+func matchItems(before, after []string) [][2]int {
+	// lengths[i][j] is the length of the longest run in before[i:] and after[j:].
+	lengths := make([][]int, len(before)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(after)+1)
+	}
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i] == after[j] {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+
+	pairs := [][2]int{}
+	for i, j := 0, 0; i < len(before) && j < len(after); {
+		switch {
+		case before[i] == after[j]:
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// tableEncoder looks up the new cells for the body rows of a table.
+type tableEncoder struct {
+	rowCount int
+	// cellFor formats the new value of a cell.
+	cellFor func(row int, header string) string
+	// cellEquals reports whether the existing text of a cell already holds the new value.
+	cellEquals func(row int, header string, text string) bool
+}
+
+// encodeTableRows replaces the body rows of a table, looking up each cell by its column header.
+// Existing rows whose cells have not changed are kept as they are.
+func encodeTableRows(node ast.Node, source []byte, encoder tableEncoder) ([]byte, error) {
+	table, ok := node.(*extension_ast.Table)
+	if !ok {
+		return source, fmt.Errorf("expected table node, got %s", node.Kind())
+	}
+
+	header, ok := table.FirstChild().(*extension_ast.TableHeader)
+	if !ok {
+		return source, fmt.Errorf("expected table to have a header row")
+	}
+
+	headers := []string{}
+	gmast.ForEachChild(header, source, func(cell ast.Node, _ int) {
+		headers = append(headers, string(cell.Text(source)))
+	})
+
+	existing := []ast.Node{}
+	for row := header.NextSibling(); row != nil; row = row.NextSibling() {
+		existing = append(existing, row)
+	}
+
+	rows := []ast.Node{header}
+	for i := 0; i < encoder.rowCount; i++ {
+		if i < len(existing) && rowUnchanged(existing[i], i, headers, source, encoder) {
+			rows = append(rows, existing[i])
+			continue
+		}
+
+		cells := make([]string, len(headers))
+		for col, name := range headers {
+			cells[col] = encoder.cellFor(i, name)
+		}
+
+		var row ast.Node
+		row, source = gmast.NewTableRow(cells, table.Alignments, source)
+		rows = append(rows, row)
+	}
+
+	gmast.ReplaceChildren(table, rows...)
+
+	return source, nil
+}
+
+// rowUnchanged reports whether every cell of an existing table row already holds its new value.
+func rowUnchanged(row ast.Node, index int, headers []string, source []byte, encoder tableEncoder) bool {
+	if row.ChildCount() != len(headers) {
+		return false
+	}
+
+	unchanged := true
+	gmast.ForEachChild(row, source, func(cell ast.Node, col int) {
+		if !encoder.cellEquals(index, headers[col], string(cell.Text(source))) {
+			unchanged = false
+		}
+	})
+	return unchanged
+}
+
+// structFieldForHeader finds the field of a struct that matches a column header,
+// by its `larkdown` tag or name, and formats it as a cell.
+func structFieldForHeader(value reflect.Value, header string) string {
+	i := fieldForColumn(value.Type(), header)
+	if i == -1 {
+		return ""
+	}
+	return formatCell(value.Field(i), value.Type().Field(i))
+}
+
+// structFieldEqualsCell reports whether a cell's text decodes to the value of the struct field for its column,
+// so that cells like "yes" aren't rewritten as "true".
+func structFieldEqualsCell(value reflect.Value, header string, text string) bool {
+	i := fieldForColumn(value.Type(), header)
+	if i == -1 {
+		return text == ""
+	}
+
+	decoded := reflect.New(value.Field(i).Type()).Elem()
+	if err := setCell(decoded, value.Type().Field(i), text); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(decoded.Interface(), value.Field(i).Interface())
+}
+
+// MarshalError collects the errors for each field that failed to marshal.
+type MarshalError struct {
+	Errors []*FieldError
+}
+
+func (e *MarshalError) add(field string, err error) {
+	e.Errors = append(e.Errors, &FieldError{Field: field, Err: err})
+}
+
+func (e *MarshalError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("failed to marshal %d field(s): %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// Unwrap returns each field's error, for use with errors.Is and errors.As.
+func (e *MarshalError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
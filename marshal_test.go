@@ -0,0 +1,291 @@
+package larkdown_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"go.abhg.dev/goldmark/hashtag"
+
+	"github.com/will-wow/larkdown"
+	"github.com/will-wow/larkdown/internal/test"
+	"github.com/will-wow/larkdown/mdfront"
+	"github.com/will-wow/larkdown/mdrender"
+)
+
+func TestMarshal(t *testing.T) {
+	t.Run("updates lists and text in place", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+		# My Recipe
+
+		Here's a long story about making dinner.
+
+		## Ingredients
+
+		- Chicken
+		- Salt
+
+		## Notes
+
+		Serve hot.
+
+		Some closing prose.
+		`)
+
+		var recipe struct {
+			Ingredients []string `larkdown:"## Ingredients > .list"`
+			Notes       string   `larkdown:"## Notes > [kind:Paragraph]:first"`
+		}
+
+		err := larkdown.Unmarshal(doc, source, &recipe)
+		require.NoError(t, err)
+
+		recipe.Ingredients = append(recipe.Ingredients, "Pepper")
+		recipe.Notes = "Serve cold."
+
+		source, err = larkdown.Marshal(doc, source, &recipe)
+		require.NoError(t, err)
+
+		var rendered bytes.Buffer
+		err = larkdown.NewNodeRenderer().Render(&rendered, source, doc)
+		require.NoError(t, err)
+
+		require.Equal(t, `# My Recipe
+
+Here's a long story about making dinner.
+
+## Ingredients
+
+- Chicken
+- Salt
+- Pepper
+
+## Notes
+
+Serve cold.
+
+Some closing prose.
+`, rendered.String())
+	})
+
+	t.Run("keeps the rest of changed list items", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+		## Steps
+
+		- [x] Preheat the **oven**
+		- Prep
+		  - Chop onions
+		- Serve
+		`, goldmark.WithExtensions(extension.TaskList))
+
+		var recipe struct {
+			Steps []string `larkdown:"## Steps > .list"`
+		}
+
+		err := larkdown.Unmarshal(doc, source, &recipe)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Preheat the oven", "PrepChop onions", "Serve"}, recipe.Steps)
+
+		recipe.Steps = []string{"Preheat the grill", "PrepareChop onions", "Light the coals", "Serve"}
+
+		original := source
+		source, err = larkdown.Marshal(doc, source, &recipe)
+		require.NoError(t, err)
+
+		expected := `## Steps
+
+- [x] Preheat the grill
+- Prepare
+  - Chop onions
+- Light the coals
+- Serve
+`
+		for _, opts := range [][]mdrender.Option{nil, {mdrender.WithLossless(original)}} {
+			var rendered bytes.Buffer
+			err = larkdown.NewNodeRenderer(opts...).Render(&rendered, source, doc)
+			require.NoError(t, err)
+			// The lossless renderer keeps the blank line the test markdown starts with.
+			require.Equal(t, expected, strings.TrimLeft(rendered.String(), "\n"))
+		}
+	})
+
+	t.Run("updates tags and table rows", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, recipeMarkdown, goldmark.WithExtensions(
+			extension.Table,
+			&hashtag.Extender{Variant: hashtag.ObsidianVariant},
+		))
+
+		recipe := TaggedRecipe{}
+		err := larkdown.Unmarshal(doc, source, &recipe)
+		require.NoError(t, err)
+
+		recipe.Tags[1] = "turkey"
+		recipe.Comments = append(recipe.Comments, Comment{Name: "Carol", Comment: "Needs salt"})
+
+		source, err = larkdown.Marshal(doc, source, recipe)
+		require.NoError(t, err)
+
+		updated := TaggedRecipe{}
+		err = larkdown.Unmarshal(doc, source, &updated)
+		require.NoError(t, err)
+
+		require.Equal(t, []string{"dinner", "turkey"}, updated.Tags)
+		require.Equal(t, recipe.Comments, updated.Comments)
+	})
+
+	t.Run("leaves unchanged fields as written", func(t *testing.T) {
+		markdown := `# My _Recipe_
+
+## Notes
+
+**Serve** hot with [link](http://x).
+
+## Comments
+
+| Name      | Comment        | Rating |
+| --------- | -------------- | ------ |
+| **Alice** | It's ~~good~~! | yes    |
+| Bob       | It's ` + "`bad`" + `     | no     |
+`
+		doc, source := test.TreeFromMd(t, markdown, goldmark.WithExtensions(extension.GFM))
+
+		type rated struct {
+			Name    string
+			Comment string
+			Rating  bool
+		}
+
+		var recipe struct {
+			Title    string              `larkdown:"[[#]]"`
+			Notes    string              `larkdown:"## Notes > [kind:Paragraph]:first"`
+			Comments []rated             `larkdown:"## Comments > .table"`
+			Raw      []map[string]string `larkdown:"## Comments > .table"`
+		}
+
+		err := larkdown.Unmarshal(doc, source, &recipe)
+		require.NoError(t, err)
+
+		source, err = larkdown.Marshal(doc, source, &recipe)
+		require.NoError(t, err)
+
+		var rendered bytes.Buffer
+		err = larkdown.NewNodeRenderer().Render(&rendered, source, doc)
+		require.NoError(t, err)
+
+		require.Equal(t, markdown, rendered.String())
+	})
+
+	t.Run("updates frontmatter and code blocks", func(t *testing.T) {
+		markdown := `---
+title: My Recipe # keep this comment
+author:
+  name: Will
+---
+
+# My Recipe
+
+` + "```yaml" + `
+servings: 2
+oven: 350
+` + "```" + `
+
+` + "```sh" + `
+echo hi
+` + "```" + `
+
+Done.
+`
+		type settings struct {
+			Servings int `yaml:"servings"`
+			Oven     int `yaml:"oven"`
+		}
+
+		var recipe struct {
+			Title    string   `larkdown:"[frontmatter:title]"`
+			Author   string   `larkdown:"[frontmatter:author.name]"`
+			Settings settings `larkdown:"# My Recipe > .code(yaml)"`
+			Script   string   `larkdown:"# My Recipe > .code(sh)"`
+		}
+
+		for _, lossless := range []bool{false, true} {
+			doc, source := test.TreeFromMd(t, markdown, goldmark.WithExtensions(&mdfront.Extender{}))
+			original := source
+
+			err := larkdown.Unmarshal(doc, source, &recipe)
+			require.NoError(t, err)
+
+			source, err = larkdown.Marshal(doc, source, &recipe)
+			require.NoError(t, err)
+			require.Equal(t, original, source, "unchanged fields don't add to the source")
+
+			recipe.Author = "Wendy"
+			recipe.Settings.Servings = 4
+			recipe.Script = "echo bye\n"
+
+			source, err = larkdown.Marshal(doc, source, &recipe)
+			require.NoError(t, err)
+
+			opts := []mdrender.Option{}
+			if lossless {
+				opts = append(opts, mdrender.WithLossless(original))
+			}
+			var rendered bytes.Buffer
+			err = larkdown.NewNodeRenderer(opts...).Render(&rendered, source, doc)
+			require.NoError(t, err)
+
+			require.Equal(t, `---
+title: My Recipe # keep this comment
+author:
+  name: Wendy
+---
+
+# My Recipe
+
+`+"```yaml"+`
+servings: 4
+oven: 350
+`+"```"+`
+
+`+"```sh"+`
+echo bye
+`+"```"+`
+
+Done.
+`, rendered.String(), "lossless: %v", lossless)
+
+			updated := recipe
+			err = larkdown.Unmarshal(doc, source, &updated)
+			require.NoError(t, err)
+			require.Equal(t, recipe, updated)
+		}
+	})
+
+	t.Run("aggregates errors", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+		# Title
+
+		- item
+		`)
+
+		out := struct {
+			Missing string   `larkdown:"## Missing"`
+			Items   int      `larkdown:"# Title > .list"`
+			Tags    []string `larkdown:"# Title > [#tag],all"`
+		}{
+			Tags: []string{"extra"},
+		}
+
+		_, err := larkdown.Marshal(doc, source, out)
+
+		var marshalErr *larkdown.MarshalError
+		require.True(t, errors.As(err, &marshalErr))
+		require.Len(t, marshalErr.Errors, 3)
+		require.ErrorContains(t, err, "field Missing: failed to match query")
+		require.ErrorContains(t, err, "field Items: unsupported field type int")
+		require.ErrorContains(t, err, "field Tags: found 0 nodes to update, but have 1 items")
+	})
+}
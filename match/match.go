@@ -11,6 +11,10 @@ import (
 	"go.abhg.dev/goldmark/hashtag"
 
 	"github.com/will-wow/larkdown/gmast"
+	"github.com/will-wow/larkdown/mdcallout"
+	"github.com/will-wow/larkdown/mdfield"
+	"github.com/will-wow/larkdown/mdfront"
+	"github.com/will-wow/larkdown/mdwiki"
 )
 
 // Interface for a node matcher.
@@ -105,7 +109,7 @@ func (m Branch) String() string {
 		return fmt.Sprintf("[%s]", level)
 	}
 
-	return fmt.Sprintf("[%s %s]", level, string(m.Name))
+	return fmt.Sprintf("[%s %s]%s", level, escapeName(string(m.Name)), caseInsensitiveSuffix(m.CaseInsensitive))
 }
 
 // Heading matches a heading by level, to get the title contents directly.
@@ -159,7 +163,30 @@ func (m Heading) String() string {
 		return fmt.Sprintf("[[%s]]", level)
 	}
 
-	return fmt.Sprintf("[[%s %s]]", level, string(m.Name))
+	return fmt.Sprintf("[[%s %s]]%s", level, escapeName(string(m.Name)), caseInsensitiveSuffix(m.CaseInsensitive))
+}
+
+// escapeName escapes the characters in a name that Parse would otherwise read as the end of a selector
+// or code language, along with surrounding whitespace, which Parse trims.
+func escapeName(name string) string {
+	var escaped strings.Builder
+	start := len(name) - len(strings.TrimLeft(name, " \t"))
+	end := len(strings.TrimRight(name, " \t"))
+	for i, c := range name {
+		if strings.ContainsRune(`\[]>)`, c) || i < start || i >= end {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(c)
+	}
+	return escaped.String()
+}
+
+// caseInsensitiveSuffix notes a case-insensitive name match with an :i pseudo-class.
+func caseInsensitiveSuffix(caseInsensitive bool) string {
+	if caseInsensitive {
+		return ":i"
+	}
+	return ""
 }
 
 // Matches an ordered or unordered list.
@@ -179,6 +206,88 @@ func (m List) String() string {
 	return ".list"
 }
 
+// Matches a list that has at least one task list item, as parsed by goldmark's extension.TaskList.
+type TaskList struct {
+	BaseNode
+}
+
+var _ Node = TaskList{}
+
+func (m TaskList) Match(node ast.Node, index int, source []byte) bool {
+	list, ok := node.(*ast.List)
+	if !ok {
+		return false
+	}
+
+	for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+		if gmast.TaskCheckBox(item) != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (m TaskList) String() string {
+	return ".tasklist"
+}
+
+// Matches a task list item, optionally only when it is checked or unchecked.
+type TaskItem struct {
+	BaseNode
+	// The checked state to match, or nil to match any task.
+	Checked *bool
+}
+
+var _ Node = TaskItem{}
+
+func (m TaskItem) Match(node ast.Node, index int, source []byte) bool {
+	checkBox := gmast.TaskCheckBox(node)
+	if checkBox == nil {
+		return false
+	}
+
+	return m.Checked == nil || *m.Checked == checkBox.IsChecked
+}
+
+func (m TaskItem) String() string {
+	if m.Checked == nil {
+		return ".task"
+	}
+	if *m.Checked {
+		return ".task[x]"
+	}
+	return ".task[ ]"
+}
+
+// Matches a fenced code block by the language in its info string.
+type CodeBlock struct {
+	BaseNode
+	// The language to match, or empty to match any code block, fenced or indented.
+	Language string
+}
+
+var _ Node = CodeBlock{}
+
+func (m CodeBlock) Match(node ast.Node, index int, source []byte) bool {
+	if m.Language == "" {
+		return node.Kind() == ast.KindFencedCodeBlock || node.Kind() == ast.KindCodeBlock
+	}
+
+	code, ok := node.(*ast.FencedCodeBlock)
+	if !ok {
+		return false
+	}
+
+	return strings.EqualFold(string(code.Language(source)), m.Language)
+}
+
+func (m CodeBlock) String() string {
+	if m.Language == "" {
+		return ".code"
+	}
+	return fmt.Sprintf(".code(%s)", escapeName(m.Language))
+}
+
 // Matches go.abhg.dev/goldmark/hashtag #tag nodes.
 type Tag struct {
 	BaseNode
@@ -195,6 +304,110 @@ func (m Tag) String() string {
 	return "[#tag]"
 }
 
+// Frontmatter matches the frontmatter of a document parsed with mdfront.Extender,
+// when it has a key.
+type Frontmatter struct {
+	BaseNode
+	// The key to match, as a dot-separated path like "author.name", or empty to match any frontmatter.
+	Key string
+}
+
+var _ Node = Frontmatter{}
+
+func (m Frontmatter) Match(node ast.Node, index int, source []byte) bool {
+	frontmatter, ok := node.(*mdfront.Node)
+	if !ok {
+		return false
+	}
+
+	_, ok = frontmatter.Lookup(m.Key)
+	return ok
+}
+
+func (m Frontmatter) String() string {
+	if m.Key == "" {
+		return "[frontmatter]"
+	}
+	return fmt.Sprintf("[frontmatter:%s]", escapeName(m.Key))
+}
+
+// WikiLink matches an Obsidian-style [[wikilink]] or ![[embed]] parsed by mdwiki.Extender, by its target page.
+type WikiLink struct {
+	BaseNode
+	// The target page to match, like "Page" for [[Page#Heading|alias]], or empty to match any wikilink.
+	// Like in Obsidian, the target is matched case-insensitively.
+	Target string
+}
+
+var _ Node = WikiLink{}
+
+func (m WikiLink) Match(node ast.Node, index int, source []byte) bool {
+	link, ok := node.(*mdwiki.Node)
+	if !ok {
+		return false
+	}
+
+	return m.Target == "" || strings.EqualFold(string(link.Target), m.Target)
+}
+
+func (m WikiLink) String() string {
+	if m.Target == "" {
+		return "[wikilink]"
+	}
+	return fmt.Sprintf("[wikilink:%s]", escapeName(m.Target))
+}
+
+// Callout matches an Obsidian or GitHub style callout parsed by mdcallout.Extender, by its type.
+// Its children are the blocks in the callout.
+type Callout struct {
+	BaseNode
+	// The callout type to match case-insensitively, like "warning" for > [!WARNING], or empty to match any callout.
+	Type string
+}
+
+var _ Node = Callout{}
+
+func (m Callout) Match(node ast.Node, index int, source []byte) bool {
+	callout, ok := node.(*mdcallout.Node)
+	if !ok {
+		return false
+	}
+
+	return m.Type == "" || strings.EqualFold(string(callout.CalloutType), m.Type)
+}
+
+func (m Callout) String() string {
+	if m.Type == "" {
+		return "[callout]"
+	}
+	return fmt.Sprintf("[callout:%s]", escapeName(m.Type))
+}
+
+// Field matches a Dataview-style inline field parsed by mdfield.Extender, like rating:: 5, by its key.
+type Field struct {
+	BaseNode
+	// The key to match case-insensitively, or empty to match any field.
+	Key string
+}
+
+var _ Node = Field{}
+
+func (m Field) Match(node ast.Node, index int, source []byte) bool {
+	field, ok := node.(*mdfield.Node)
+	if !ok {
+		return false
+	}
+
+	return m.Key == "" || strings.EqualFold(string(field.Key), m.Key)
+}
+
+func (m Field) String() string {
+	if m.Key == "" {
+		return "[field]"
+	}
+	return fmt.Sprintf("[field:%s]", escapeName(m.Key))
+}
+
 // Table matches a table that wraps rows and cells.
 type Table struct {
 	BaseNode
@@ -211,6 +424,78 @@ func (m Table) String() string {
 	return ".table"
 }
 
+// Footnote matches a footnote definition parsed by goldmark's extension.Footnote, by its label.
+// The definitions are collected in a list at the end of the document,
+// so find them with FindAll, or under [kind:FootnoteList].
+type Footnote struct {
+	BaseNode
+	// The label to match, like "1" for [^1], or empty to match any footnote.
+	Label string
+}
+
+var _ Node = Footnote{}
+
+func (m Footnote) Match(node ast.Node, index int, source []byte) bool {
+	footnote, ok := node.(*extension_ast.Footnote)
+	if !ok {
+		return false
+	}
+
+	return m.Label == "" || string(footnote.Ref) == m.Label
+}
+
+func (m Footnote) String() string {
+	if m.Label == "" {
+		return "[footnote]"
+	}
+	return fmt.Sprintf("[footnote:%s]", escapeName(m.Label))
+}
+
+// DefinitionTerm matches a term in a definition list parsed by goldmark's extension.DefinitionList, by its name.
+// Like a heading branch, its children are the descriptions that follow it, until the next term.
+type DefinitionTerm struct {
+	// The term to match, or empty to match any term.
+	Name string
+}
+
+var _ Node = DefinitionTerm{}
+
+func (m DefinitionTerm) Match(node ast.Node, index int, source []byte) bool {
+	if node.Kind() != extension_ast.KindDefinitionTerm {
+		return false
+	}
+
+	return m.Name == "" || string(node.Text(source)) == m.Name
+}
+
+// A term's descriptions end at the next term that has its own descriptions.
+// Terms that share descriptions are next to each other.
+func (m DefinitionTerm) EndMatch(node ast.Node) bool {
+	if node.Kind() != extension_ast.KindDefinitionTerm {
+		return false
+	}
+
+	prev := node.PreviousSibling()
+	return prev != nil && prev.Kind() == extension_ast.KindDefinitionDescription
+}
+
+// For terms, the next node is the next sibling.
+func (m DefinitionTerm) NextNode(self ast.Node) ast.Node {
+	return self.NextSibling()
+}
+
+// Terms are branches whose children are the description siblings.
+func (m DefinitionTerm) IsFlatBranch() bool {
+	return true
+}
+
+func (m DefinitionTerm) String() string {
+	if m.Name == "" {
+		return "[term]"
+	}
+	return fmt.Sprintf("[term:%s]", escapeName(m.Name))
+}
+
 // Wraps another query, only when it's the nth child of the parent.
 type Index struct {
 	Index int
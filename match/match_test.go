@@ -7,10 +7,14 @@ import (
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/extension"
+	extension_ast "github.com/yuin/goldmark/extension/ast"
 
 	"github.com/will-wow/larkdown"
 	"github.com/will-wow/larkdown/internal/test"
 	"github.com/will-wow/larkdown/match"
+	"github.com/will-wow/larkdown/mdcallout"
+	"github.com/will-wow/larkdown/mdfield"
+	"github.com/will-wow/larkdown/mdwiki"
 	"github.com/will-wow/larkdown/query"
 )
 
@@ -128,3 +132,296 @@ func TestTable(t *testing.T) {
 		)
 	})
 }
+
+func TestTaskList(t *testing.T) {
+	md := `
+		# Todo
+
+		- not a task
+
+		Then:
+
+		- [ ] first
+		- [x] second
+		  - [x] nested
+		  - [ ] nested again
+		- plain
+		`
+
+	t.Run("should match and decode a task list", func(t *testing.T) {
+		tree, source := test.TreeFromMd(t, md, goldmark.WithExtensions(extension.TaskList))
+
+		matcher := []match.Node{
+			match.Branch{Level: 1},
+			match.TaskList{},
+		}
+
+		tasks, err := larkdown.Find(tree, source, matcher, larkdown.DecodeTasks)
+		require.NoError(t, err)
+
+		require.Equal(t, []larkdown.Task{
+			{Text: "first", Children: []larkdown.Task{}},
+			{Text: "second", Checked: true, Children: []larkdown.Task{
+				{Text: "nested", Checked: true, Children: []larkdown.Task{}},
+				{Text: "nested again", Children: []larkdown.Task{}},
+			}},
+			{Text: "plain", Children: []larkdown.Task{}},
+		}, tasks)
+	})
+
+	t.Run("should find tasks by checked state", func(t *testing.T) {
+		tree, source := test.TreeFromMd(t, md, goldmark.WithExtensions(extension.TaskList))
+
+		unchecked := false
+		matcher := []match.Node{match.Branch{Level: 1}}
+
+		tasks, err := larkdown.FindAll(tree, source, matcher, match.TaskItem{Checked: &unchecked}, larkdown.DecodeTask)
+		require.NoError(t, err)
+
+		require.Len(t, tasks, 2)
+		require.Equal(t, "first", tasks[0].Text)
+		require.Equal(t, "nested again", tasks[1].Text)
+	})
+}
+
+func TestCodeBlock(t *testing.T) {
+	tree, source := test.TreeFromMd(t, "# Runbook\n\n"+
+		"```sh\necho hi\n```\n\n"+
+		"```yaml\nname: web\nreplicas: 2\n```\n\n"+
+		"```json\n{\"name\": \"api\", \"replicas\": 3}\n```\n\n"+
+		"```toml\nname = \"worker\"\nreplicas = 4\n```\n",
+	)
+
+	type config struct {
+		Name     string `yaml:"name" json:"name" toml:"name"`
+		Replicas int    `yaml:"replicas" json:"replicas" toml:"replicas"`
+	}
+
+	t.Run("should match by language and decode raw code", func(t *testing.T) {
+		matcher := []match.Node{match.Branch{Level: 1}, match.CodeBlock{Language: "sh"}}
+
+		code, err := larkdown.Find(tree, source, matcher, larkdown.DecodeCode)
+		require.NoError(t, err)
+		require.Equal(t, "echo hi\n", code)
+	})
+
+	t.Run("should match any code block", func(t *testing.T) {
+		matcher := []match.Node{match.Branch{Level: 1}}
+
+		blocks, err := larkdown.FindAll(tree, source, matcher, match.CodeBlock{}, larkdown.DecodeCode)
+		require.NoError(t, err)
+		require.Len(t, blocks, 4)
+	})
+
+	for language, expected := range map[string]config{
+		"yaml": {Name: "web", Replicas: 2},
+		"JSON": {Name: "api", Replicas: 3},
+		"toml": {Name: "worker", Replicas: 4},
+	} {
+		t.Run("should decode "+language, func(t *testing.T) {
+			matcher := []match.Node{match.Branch{Level: 1}, match.CodeBlock{Language: language}}
+
+			decoded, err := larkdown.Find(tree, source, matcher, larkdown.DecodeCodeAs[config])
+			require.NoError(t, err)
+			require.Equal(t, expected, decoded)
+		})
+	}
+
+	t.Run("should error on unknown languages", func(t *testing.T) {
+		matcher := []match.Node{match.Branch{Level: 1}, match.CodeBlock{Language: "sh"}}
+
+		_, err := larkdown.Find(tree, source, matcher, larkdown.DecodeCodeAs[config])
+		require.ErrorContains(t, err, `cannot decode code block with language "sh"`)
+	})
+
+	t.Run("should unmarshal into struct fields", func(t *testing.T) {
+		var runbook struct {
+			Script string         `larkdown:"# Runbook > .code(sh)"`
+			Web    config         `larkdown:"# Runbook > .code(yaml)"`
+			API    map[string]any `larkdown:"# Runbook > .code(json)"`
+		}
+
+		err := larkdown.Unmarshal(tree, source, &runbook)
+		require.NoError(t, err)
+		require.Equal(t, "echo hi\n", runbook.Script)
+		require.Equal(t, config{Name: "web", Replicas: 2}, runbook.Web)
+		require.Equal(t, "api", runbook.API["name"])
+	})
+}
+
+func TestFootnote(t *testing.T) {
+	tree, source := test.TreeFromMd(t, `
+		# Notes
+
+		Some text[^1] with notes[^note].
+
+		[^1]: The first note.
+		[^note]: The second note.
+		`, goldmark.WithExtensions(extension.Footnote))
+
+	t.Run("should find all footnotes", func(t *testing.T) {
+		notes, err := larkdown.FindAll(tree, source, nil, match.Footnote{}, larkdown.DecodeText)
+		require.NoError(t, err)
+		require.Equal(t, []string{"The first note.", "The second note."}, notes)
+	})
+
+	t.Run("should find a footnote by label", func(t *testing.T) {
+		matcher := []match.Node{match.NodeOfKind{Kind: extension_ast.KindFootnoteList}, match.Footnote{Label: "note"}}
+
+		note, err := larkdown.Find(tree, source, matcher, larkdown.DecodeText)
+		require.NoError(t, err)
+		require.Equal(t, "The second note.", note)
+	})
+}
+
+func TestDefinitionTerm(t *testing.T) {
+	tree, source := test.TreeFromMd(t, `
+		# Glossary
+
+		Apple
+		:   A fruit.
+		:   A computer company.
+
+		Orange
+		Tangerine
+		:   Citrus fruits.
+		`, goldmark.WithExtensions(extension.DefinitionList))
+
+	t.Run("should decode a definition list", func(t *testing.T) {
+		matcher := []match.Node{match.Branch{Level: 1}, match.NodeOfKind{Kind: extension_ast.KindDefinitionList}}
+
+		glossary, err := larkdown.Find(tree, source, matcher, larkdown.DecodeDefinitionList)
+		require.NoError(t, err)
+		require.Equal(t, map[string][]string{
+			"Apple":     {"A fruit.", "A computer company."},
+			"Orange":    {"Citrus fruits."},
+			"Tangerine": {"Citrus fruits."},
+		}, glossary)
+	})
+
+	t.Run("should find the descriptions of a term", func(t *testing.T) {
+		matcher := []match.Node{
+			match.Branch{Level: 1},
+			match.NodeOfKind{Kind: extension_ast.KindDefinitionList},
+			match.DefinitionTerm{Name: "Orange"},
+		}
+
+		descriptions, err := larkdown.FindAll(
+			tree, source, matcher, match.NodeOfKind{Kind: extension_ast.KindDefinitionDescription}, larkdown.DecodeText,
+		)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Citrus fruits."}, descriptions)
+	})
+}
+
+func TestWikiLink(t *testing.T) {
+	tree, source := test.TreeFromMd(t, `
+		# Reading
+
+		## Books
+
+		- [[Dune]] by [[Frank Herbert|Herbert]]
+		- [[dune#Characters|Paul]]
+
+		## Covers
+
+		![[dune.png]]
+		`, goldmark.WithExtensions(&mdwiki.Extender{}))
+
+	t.Run("should find all wikilinks in a branch", func(t *testing.T) {
+		matcher := []match.Node{match.Branch{Level: 2, Name: []byte("Books")}}
+
+		links, err := larkdown.FindAll(tree, source, matcher, match.WikiLink{}, larkdown.DecodeWikiLink)
+		require.NoError(t, err)
+		require.Equal(t, []larkdown.WikiLink{
+			{Target: "Dune"},
+			{Target: "Frank Herbert", Alias: "Herbert"},
+			{Target: "dune", Heading: "Characters", Alias: "Paul"},
+		}, links)
+	})
+
+	t.Run("should match a target case-insensitively", func(t *testing.T) {
+		links, err := larkdown.FindAll(tree, source, nil, match.WikiLink{Target: "Dune"}, larkdown.DecodeText)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Dune", "Paul"}, links)
+	})
+
+	t.Run("should find embeds", func(t *testing.T) {
+		links, err := larkdown.FindAll(tree, source, nil, match.WikiLink{Target: "dune.png"}, larkdown.DecodeWikiLink)
+		require.NoError(t, err)
+		require.Equal(t, []larkdown.WikiLink{{Target: "dune.png", Embed: true}}, links)
+	})
+}
+
+func TestCallout(t *testing.T) {
+	tree, source := test.TreeFromMd(t, `
+		# Recipe
+
+		> [!WARNING] Hot
+		> - Use oven mitts
+		> - Let it cool
+
+		> [!tip]
+		> Add salt.
+		`, goldmark.WithExtensions(&mdcallout.Extender{}))
+
+	t.Run("should find a callout by type", func(t *testing.T) {
+		matcher := []match.Node{match.Branch{Level: 1}, match.Callout{Type: "warning"}, match.List{}}
+
+		warnings, err := larkdown.Find(tree, source, matcher, larkdown.DecodeListItems)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Use oven mitts", "Let it cool"}, warnings)
+	})
+
+	t.Run("should find all callouts", func(t *testing.T) {
+		callouts, err := larkdown.FindAll(tree, source, nil, match.Callout{}, larkdown.DecodeText)
+		require.NoError(t, err)
+		require.Equal(t, []string{"Use oven mittsLet it cool", "Add salt."}, callouts)
+	})
+}
+
+func TestField(t *testing.T) {
+	tree, source := test.TreeFromMd(t, `
+		# Reading Log
+
+		## Dune
+
+		rating:: 5
+		author:: [[Frank Herbert]]
+
+		- Finished in [days:: 12], then reread it.
+		- rating:: 4
+
+		## Hyperion
+
+		rating:: 4
+		`, goldmark.WithExtensions(&mdfield.Extender{}, &mdwiki.Extender{}))
+
+	t.Run("should find all fields with a key in a branch", func(t *testing.T) {
+		matcher := []match.Node{match.Branch{Level: 2, Name: []byte("Dune")}}
+
+		ratings, err := larkdown.FindAll(tree, source, matcher, match.Field{Key: "Rating"}, larkdown.DecodeText)
+		require.NoError(t, err)
+		require.Equal(t, []string{"5", "4"}, ratings)
+	})
+
+	t.Run("should decode the fields in a branch", func(t *testing.T) {
+		matcher := []match.Node{match.Branch{Level: 2, Name: []byte("Dune")}}
+
+		fields, err := larkdown.Find(tree, source, matcher, larkdown.DecodeFields)
+		require.NoError(t, err)
+		require.Equal(t, map[string][]string{
+			"rating": {"5", "4"},
+			"author": {"Frank Herbert"},
+			"days":   {"12"},
+		}, fields)
+	})
+
+	t.Run("should decode the fields in a node", func(t *testing.T) {
+		matcher := []match.Node{match.Branch{Level: 2, Name: []byte("Dune")}, match.List{}}
+
+		fields, err := larkdown.Find(tree, source, matcher, larkdown.DecodeFields)
+		require.NoError(t, err)
+		require.Equal(t, map[string][]string{"rating": {"4"}, "days": {"12"}}, fields)
+	})
+}
@@ -0,0 +1,611 @@
+package match
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yuin/goldmark/ast"
+
+	"github.com/will-wow/larkdown/internal/kinds"
+)
+
+// SyntaxError is returned by Parse when a query string is malformed.
+type SyntaxError struct {
+	// The query that failed to parse.
+	Query string
+	// The 1-based column of the offending character, counted in runes.
+	Column int
+	// A description of the problem.
+	Message string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error in query %q at column %d: %s", e.Query, e.Column, e.Message)
+}
+
+// Parse compiles a selector-style query string into a slice of matchers.
+//
+// Selectors are separated by optional '>' characters, and use the same syntax
+// that each matcher prints with String(), so a printed query can be parsed back:
+//
+//	[## Subheading]   Branch{Level: 2, Name: "Subheading"}
+//	[#?]              Branch with any level and name
+//	[[# Title]]       Heading{Level: 1, Name: "Title"}
+//	.list             List
+//	.table            Table
+//	.any              AnyNode
+//	.tasklist         TaskList
+//	.task             TaskItem, or .task[x] and .task[ ] for checked and unchecked tasks
+//	.code(yaml)       CodeBlock{Language: "yaml"}, or .code for any code block
+//	[#tag]            Tag
+//	[kind:Paragraph]  NodeOfKind{Kind: ast.KindParagraph}
+//	[frontmatter:key] Frontmatter{Key: "key"}, or [frontmatter] for any frontmatter
+//	[footnote:label]  Footnote{Label: "label"}, or [footnote] for any footnote
+//	[term:Name]       DefinitionTerm{Name: "Name"}, or [term] for any term
+//	[wikilink:Page]   WikiLink{Target: "Page"}, or [wikilink] for any wikilink
+//	[callout:warning] Callout{Type: "warning"}, or [callout] for any callout
+//	[field:rating]    Field{Key: "rating"}, or [field] for any inline field
+//	[2].any           Index{Index: 2, Node: AnyNode}
+//
+// Branches can also be written without brackets, ending at the next '>':
+//
+//	# Recipe > ## Ingredients > .list:first
+//
+// Any selector may be followed by the pseudo-classes :first or :nth(n), which
+// wrap it in an Index, and headings and branches accept :i to match case-insensitively.
+// Use a backslash to escape a special character in a name.
+func Parse(query string) ([]Node, error) {
+	p := &parser{query: query}
+
+	nodes := []Node{}
+	for {
+		p.skipSpace()
+		if p.done() {
+			break
+		}
+
+		if len(nodes) > 0 && p.peek() == '>' {
+			p.pos++
+			p.skipSpace()
+			if p.done() {
+				return nil, p.errorf("expected a selector after '>'")
+			}
+		}
+
+		node, err := p.parseSelector()
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+
+	if len(nodes) == 0 {
+		return nil, p.errorf("empty query")
+	}
+
+	return nodes, nil
+}
+
+// MustParse is like Parse, but panics on a syntax error.
+// Useful for initializing queries in package-level variables.
+func MustParse(query string) []Node {
+	nodes, err := Parse(query)
+	if err != nil {
+		panic(err)
+	}
+	return nodes
+}
+
+// parser tracks the position in a query string being parsed.
+type parser struct {
+	query string
+	pos   int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.query)
+}
+
+func (p *parser) peek() byte {
+	if p.done() {
+		return 0
+	}
+	return p.query[p.pos]
+}
+
+func (p *parser) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(p.query[p.pos:], prefix)
+}
+
+func (p *parser) skipSpace() {
+	for !p.done() && isSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return p.errorAt(p.pos, format, args...)
+}
+
+func (p *parser) errorAt(pos int, format string, args ...any) error {
+	return &SyntaxError{
+		Query:   p.query,
+		Column:  utf8.RuneCountInString(p.query[:pos]) + 1,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// parseSelector parses a single matcher, including any index prefix or pseudo-classes.
+func (p *parser) parseSelector() (Node, error) {
+	var node Node
+	var err error
+
+	switch {
+	case p.hasPrefix("[["):
+		node, err = p.parseHeading()
+	case p.hasPrefix("[#tag]"):
+		p.pos += len("[#tag]")
+		node = Tag{}
+	case p.hasPrefix("[kind:"):
+		node, err = p.parseKind()
+	case p.hasPrefix("[frontmatter"):
+		node, err = p.parseFrontmatter()
+	case p.hasPrefix("[footnote"):
+		node, err = p.parseFootnote()
+	case p.hasPrefix("[term"):
+		node, err = p.parseDefinitionTerm()
+	case p.hasPrefix("[wikilink"):
+		node, err = p.parseWikiLink()
+	case p.hasPrefix("[callout"):
+		node, err = p.parseCallout()
+	case p.hasPrefix("[field"):
+		node, err = p.parseField()
+	case p.hasPrefix("[#"):
+		node, err = p.parseBracketBranch()
+	case p.hasPrefix("["):
+		return p.parseIndex()
+	case p.hasPrefix("."):
+		node, err = p.parseClass()
+	case p.hasPrefix("#"):
+		node, err = p.parseBareBranch()
+	default:
+		return nil, p.errorf("unexpected character %q", p.peek())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return p.parsePseudoClasses(node)
+}
+
+// parseIndex parses an index prefix like [2].list
+func (p *parser) parseIndex() (Node, error) {
+	start := p.pos
+	// Skip the [
+	p.pos++
+
+	index, err := p.parseInt()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek() != ']' {
+		return nil, p.errorf("expected ']' to close index")
+	}
+	p.pos++
+
+	if p.done() || isSpace(p.peek()) || p.peek() == '>' {
+		return nil, p.errorAt(start, "index must be followed by a selector")
+	}
+
+	node, err := p.parseSelector()
+	if err != nil {
+		return nil, err
+	}
+
+	return Index{Index: index, Node: node}, nil
+}
+
+// parseHeading parses a heading like [[## Name]]
+func (p *parser) parseHeading() (Node, error) {
+	// Skip the [[
+	p.pos += 2
+
+	level, name, err := p.parseLevelAndName("]]")
+	if err != nil {
+		return nil, err
+	}
+
+	return Heading{Level: level, Name: name}, nil
+}
+
+// parseBracketBranch parses a branch like [## Name]
+func (p *parser) parseBracketBranch() (Node, error) {
+	// Skip the [
+	p.pos++
+
+	level, name, err := p.parseLevelAndName("]")
+	if err != nil {
+		return nil, err
+	}
+
+	return Branch{Level: level, Name: name}, nil
+}
+
+// parseBareBranch parses a branch like ## Name, which ends at the next '>'.
+func (p *parser) parseBareBranch() (Node, error) {
+	level, err := p.parseLevel()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.done() && !isSpace(p.peek()) && p.peek() != '>' && p.peek() != ':' {
+		return nil, p.errorf("expected a space after heading level")
+	}
+	p.skipSpace()
+
+	name := p.parseName(func() bool {
+		return p.peek() == '>' || p.atTrailingPseudoClass()
+	})
+
+	return Branch{Level: level, Name: name}, nil
+}
+
+// parseLevelAndName parses the inside of a bracketed heading or branch, and the closing brackets.
+func (p *parser) parseLevelAndName(closing string) (level int, name []byte, err error) {
+	level, err = p.parseLevel()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if !p.hasPrefix(closing) {
+		if p.peek() != ' ' {
+			return 0, nil, p.errorf("expected a space after heading level")
+		}
+		p.pos++
+
+		name = p.parseName(func() bool { return p.hasPrefix(closing) })
+	}
+
+	if !p.hasPrefix(closing) {
+		return 0, nil, p.errorf("expected %q to close heading", closing)
+	}
+	p.pos += len(closing)
+
+	return level, name, nil
+}
+
+// parseLevel parses a heading level as a run of #s, or #? for any level.
+func (p *parser) parseLevel() (int, error) {
+	if p.hasPrefix("#?") {
+		p.pos += 2
+		return 0, nil
+	}
+
+	start := p.pos
+	for p.peek() == '#' {
+		p.pos++
+	}
+
+	level := p.pos - start
+	if level == 0 {
+		return 0, p.errorf("expected a heading level")
+	}
+	if level > 6 {
+		return 0, p.errorAt(start, "heading level must be between 1 and 6, got %d", level)
+	}
+	return level, nil
+}
+
+// parseName reads a name until the end of the query or isEnd returns true,
+// handling backslash escapes and trimming surrounding whitespace.
+func (p *parser) parseName(isEnd func() bool) []byte {
+	var name strings.Builder
+	// Track the end of escaped characters, so they aren't trimmed.
+	keep := 0
+
+	for !p.done() && !isEnd() {
+		c := p.peek()
+		if c == '\\' && p.pos+1 < len(p.query) {
+			p.pos++
+			c = p.peek()
+			name.WriteByte(c)
+			keep = name.Len()
+		} else {
+			name.WriteByte(c)
+		}
+		p.pos++
+	}
+
+	value := name.String()
+	trimmed := strings.TrimRight(value, " \t")
+	if len(trimmed) < keep {
+		trimmed = value[:keep]
+	}
+
+	if trimmed == "" {
+		return nil
+	}
+	return []byte(trimmed)
+}
+
+// parseKind parses a node kind matcher like [kind:Paragraph]
+func (p *parser) parseKind() (Node, error) {
+	p.pos += len("[kind:")
+	start := p.pos
+
+	end := strings.IndexByte(p.query[p.pos:], ']')
+	if end == -1 {
+		return nil, p.errorAt(len(p.query), "expected ']' to close kind")
+	}
+
+	name := p.query[p.pos : p.pos+end]
+	kind, ok := kindByName(name)
+	if !ok {
+		return nil, p.errorAt(start, "unknown node kind %q", name)
+	}
+	p.pos += end + 1
+
+	return NodeOfKind{Kind: kind}, nil
+}
+
+// parseFrontmatter parses a frontmatter matcher like [frontmatter:tags]
+func (p *parser) parseFrontmatter() (Node, error) {
+	key, err := p.parseBracketValue("frontmatter", "frontmatter key")
+	if err != nil {
+		return nil, err
+	}
+	return Frontmatter{Key: key}, nil
+}
+
+// parseFootnote parses a footnote matcher like [footnote:1]
+func (p *parser) parseFootnote() (Node, error) {
+	label, err := p.parseBracketValue("footnote", "footnote label")
+	if err != nil {
+		return nil, err
+	}
+	return Footnote{Label: label}, nil
+}
+
+// parseDefinitionTerm parses a definition term matcher like [term:Apple]
+func (p *parser) parseDefinitionTerm() (Node, error) {
+	name, err := p.parseBracketValue("term", "term name")
+	if err != nil {
+		return nil, err
+	}
+	return DefinitionTerm{Name: name}, nil
+}
+
+// parseWikiLink parses a wikilink matcher like [wikilink:Page]
+func (p *parser) parseWikiLink() (Node, error) {
+	target, err := p.parseBracketValue("wikilink", "wikilink target")
+	if err != nil {
+		return nil, err
+	}
+	return WikiLink{Target: target}, nil
+}
+
+// parseCallout parses a callout matcher like [callout:warning]
+func (p *parser) parseCallout() (Node, error) {
+	calloutType, err := p.parseBracketValue("callout", "callout type")
+	if err != nil {
+		return nil, err
+	}
+	return Callout{Type: calloutType}, nil
+}
+
+// parseField parses an inline field matcher like [field:rating]
+func (p *parser) parseField() (Node, error) {
+	key, err := p.parseBracketValue("field", "field key")
+	if err != nil {
+		return nil, err
+	}
+	return Field{Key: key}, nil
+}
+
+// parseBracketValue parses a bracketed selector like [name] or [name:value], returning the value.
+func (p *parser) parseBracketValue(name string, valueName string) (string, error) {
+	p.pos += len("[" + name)
+
+	if p.hasPrefix("]") {
+		p.pos++
+		return "", nil
+	}
+	if !p.hasPrefix(":") {
+		return "", p.errorf("expected ':' or ']' after %s", name)
+	}
+	p.pos++
+	start := p.pos
+
+	p.skipSpace()
+	value := p.parseName(func() bool { return p.peek() == ']' })
+	if p.done() {
+		return "", p.errorAt(len(p.query), "expected ']' to close %s", name)
+	}
+	if value == nil {
+		return "", p.errorAt(start, "expected a %s", valueName)
+	}
+	p.pos++
+
+	return string(value), nil
+}
+
+// parseClass parses a class-style matcher like .list
+func (p *parser) parseClass() (Node, error) {
+	start := p.pos
+	// Skip the .
+	p.pos++
+	for !p.done() && isIdentChar(p.peek()) {
+		p.pos++
+	}
+
+	name := p.query[start+1 : p.pos]
+	switch name {
+	case "list":
+		return List{}, nil
+	case "table":
+		return Table{}, nil
+	case "any":
+		return AnyNode{}, nil
+	case "tasklist":
+		return TaskList{}, nil
+	case "task":
+		return p.parseTaskState()
+	case "code":
+		return p.parseCodeLanguage()
+	case "":
+		return nil, p.errorAt(start, "expected a class name after '.'")
+	default:
+		return nil, p.errorAt(start, "unknown selector %q", "."+name)
+	}
+}
+
+// parseTaskState parses an optional [x] or [ ] after .task to match checked or unchecked tasks.
+func (p *parser) parseTaskState() (Node, error) {
+	checked := false
+	switch {
+	case p.hasPrefix("[x]"), p.hasPrefix("[X]"):
+		checked = true
+	case p.hasPrefix("[ ]"):
+		checked = false
+	default:
+		return TaskItem{}, nil
+	}
+	p.pos += len("[ ]")
+
+	return TaskItem{Checked: &checked}, nil
+}
+
+// parseCodeLanguage parses an optional (language) after .code
+func (p *parser) parseCodeLanguage() (Node, error) {
+	if p.peek() != '(' {
+		return CodeBlock{}, nil
+	}
+	start := p.pos
+	p.pos++
+	p.skipSpace()
+
+	language := p.parseName(func() bool { return p.peek() == ')' })
+	if p.peek() != ')' {
+		return nil, p.errorAt(start, "expected ')' to close code language")
+	}
+	if language == nil {
+		return nil, p.errorAt(start+1, "expected a code language")
+	}
+	p.pos++
+
+	return CodeBlock{Language: string(language)}, nil
+}
+
+// parsePseudoClasses applies any :first, :nth(n), or :i suffixes to a node.
+func (p *parser) parsePseudoClasses(node Node) (Node, error) {
+	for p.peek() == ':' {
+		start := p.pos
+
+		switch {
+		case p.hasPrefix(":first"):
+			p.pos += len(":first")
+			node = Index{Index: 0, Node: node}
+		case p.hasPrefix(":nth("):
+			p.pos += len(":nth(")
+			index, err := p.parseInt()
+			if err != nil {
+				return nil, err
+			}
+			if p.peek() != ')' {
+				return nil, p.errorf("expected ')' to close :nth")
+			}
+			p.pos++
+			node = Index{Index: index, Node: node}
+		case p.hasPrefix(":i"):
+			p.pos += len(":i")
+			switch n := node.(type) {
+			case Branch:
+				n.CaseInsensitive = true
+				node = n
+			case Heading:
+				n.CaseInsensitive = true
+				node = n
+			default:
+				return nil, p.errorAt(start, ":i only applies to headings and branches")
+			}
+		default:
+			return nil, p.errorf("unknown pseudo-class")
+		}
+	}
+
+	return node, nil
+}
+
+// atTrailingPseudoClass reports whether the rest of a bare name is only pseudo-classes,
+// so that "# Step 1: Prep" keeps its colon but "# Title:i" does not.
+func (p *parser) atTrailingPseudoClass() bool {
+	if p.peek() != ':' {
+		return false
+	}
+
+	rest := p.query[p.pos:]
+	if end := strings.IndexByte(rest, '>'); end != -1 {
+		rest = rest[:end]
+	}
+	rest = strings.TrimRight(rest, " \t")
+
+	for rest != "" {
+		switch {
+		case strings.HasPrefix(rest, ":first"):
+			rest = rest[len(":first"):]
+		case strings.HasPrefix(rest, ":nth("):
+			end := strings.IndexByte(rest, ')')
+			if end == -1 {
+				return false
+			}
+			if _, err := strconv.Atoi(rest[len(":nth("):end]); err != nil {
+				return false
+			}
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, ":i"):
+			rest = rest[len(":i"):]
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *parser) parseInt() (int, error) {
+	start := p.pos
+	for !p.done() && p.peek() >= '0' && p.peek() <= '9' {
+		p.pos++
+	}
+	if start == p.pos {
+		return 0, p.errorf("expected a number")
+	}
+
+	n, err := strconv.Atoi(p.query[start:p.pos])
+	if err != nil {
+		return 0, p.errorAt(start, "invalid number: %s", err)
+	}
+	return n, nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isIdentChar(c byte) bool {
+	return c == '-' || c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// kindByName looks up a registered ast.NodeKind by its name.
+// This includes custom kinds from extensions, as long as they have been registered.
+func kindByName(name string) (kind ast.NodeKind, ok bool) {
+	for _, kind := range kinds.All() {
+		if kind.String() == name {
+			return kind, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,165 @@
+package match_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark/ast"
+
+	"github.com/will-wow/larkdown/internal/test"
+	"github.com/will-wow/larkdown/match"
+	"github.com/will-wow/larkdown/query"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("bare branches with pseudo-classes", func(t *testing.T) {
+		nodes, err := match.Parse("# Recipe > ## Ingredients > .list:first")
+		require.NoError(t, err)
+		require.Equal(t, []match.Node{
+			match.Branch{Level: 1, Name: []byte("Recipe")},
+			match.Branch{Level: 2, Name: []byte("Ingredients")},
+			match.Index{Index: 0, Node: match.List{}},
+		}, nodes)
+	})
+
+	t.Run("bare branch names can contain colons", func(t *testing.T) {
+		nodes, err := match.Parse("## Step 1: Prep:i > .any:nth(2)")
+		require.NoError(t, err)
+		require.Equal(t, []match.Node{
+			match.Branch{Level: 2, Name: []byte("Step 1: Prep"), CaseInsensitive: true},
+			match.Index{Index: 2, Node: match.AnyNode{}},
+		}, nodes)
+	})
+
+	t.Run("escaped characters", func(t *testing.T) {
+		nodes, err := match.Parse(`[## a \] b] > # c \> d`)
+		require.NoError(t, err)
+		require.Equal(t, []match.Node{
+			match.Branch{Level: 2, Name: []byte("a ] b")},
+			match.Branch{Level: 1, Name: []byte("c > d")},
+		}, nodes)
+	})
+
+	t.Run("kinds", func(t *testing.T) {
+		nodes, err := match.Parse("[kind:Paragraph] [kind:Link]")
+		require.NoError(t, err)
+		require.Equal(t, []match.Node{
+			match.NodeOfKind{Kind: ast.KindParagraph},
+			match.NodeOfKind{Kind: ast.KindLink},
+		}, nodes)
+	})
+}
+
+func TestParseRoundTrip(t *testing.T) {
+	checked, unchecked := true, false
+	matchers := []match.Node{
+		match.Branch{Level: 2, Name: []byte("Subheading")},
+		match.Branch{Level: 0, Name: []byte("Any Level")},
+		match.Branch{Level: 3},
+		match.Branch{},
+		match.Branch{Level: 1, Name: []byte("Tags"), CaseInsensitive: true},
+		match.Heading{Level: 1, Name: []byte("Title")},
+		match.Heading{Level: 2},
+		match.Heading{Name: []byte("Title"), CaseInsensitive: true},
+		match.List{},
+		match.Table{},
+		match.Tag{},
+		match.AnyNode{},
+		match.TaskList{},
+		match.TaskItem{},
+		match.TaskItem{Checked: &checked},
+		match.TaskItem{Checked: &unchecked},
+		match.CodeBlock{},
+		match.CodeBlock{Language: "yaml"},
+		match.NodeOfKind{Kind: ast.KindFencedCodeBlock},
+		match.Frontmatter{},
+		match.Frontmatter{Key: "author.name"},
+		match.Footnote{},
+		match.Footnote{Label: "note"},
+		match.DefinitionTerm{},
+		match.DefinitionTerm{Name: "Apple"},
+		match.WikiLink{},
+		match.WikiLink{Target: "My Page"},
+		match.Callout{},
+		match.Callout{Type: "warning"},
+		match.Field{},
+		match.Field{Key: "rating"},
+		match.Index{Index: 2, Node: match.AnyNode{}},
+		match.Index{Index: 0, Node: match.Branch{Level: 2, Name: []byte("Nested")}},
+		match.Branch{Level: 2, Name: []byte("[draft] a > b")},
+		match.Branch{Level: 2, Name: []byte(`C:\Users\`)},
+		match.Heading{Level: 1, Name: []byte("Closing]]")},
+		match.Heading{Level: 1, Name: []byte(" padded ")},
+		match.WikiLink{Target: "Page]"},
+		match.Frontmatter{Key: "odd]key"},
+		match.CodeBlock{Language: "c)x"},
+		match.CodeBlock{Language: `a\]b`},
+		match.CodeBlock{Language: " padded "},
+	}
+
+	for _, matcher := range matchers {
+		t.Run(matcher.String(), func(t *testing.T) {
+			nodes, err := match.Parse(matcher.String())
+			require.NoError(t, err)
+			require.Equal(t, []match.Node{matcher}, nodes)
+		})
+	}
+
+	t.Run("QueryError output", func(t *testing.T) {
+		nodes, err := match.Parse("[# Title][## Subheading].list[4].any")
+		require.NoError(t, err)
+		require.Equal(t, []match.Node{
+			match.Branch{Level: 1, Name: []byte("Title")},
+			match.Branch{Level: 2, Name: []byte("Subheading")},
+			match.List{},
+			match.Index{Index: 4, Node: match.AnyNode{}},
+		}, nodes)
+	})
+}
+
+func TestParseErrors(t *testing.T) {
+	cases := []struct {
+		query  string
+		column int
+	}{
+		{"", 1},
+		{"## Heading >", 13},
+		{".list > .lisst", 9},
+		{"[## Heading", 12},
+		{"[kind:NotAKind]", 7},
+		{"####### Too deep", 1},
+		{".list:last", 6},
+		{"[2]", 1},
+		{".table:i", 7},
+		{"> .list", 1},
+		{"[frontmatter:]", 14},
+		{"[frontmatter.tags]", 13},
+		{"[footnote:]", 11},
+		{"[term:Apple", 12},
+		{"[wikilink:]", 11},
+		{"[callout", 9},
+		{"## Café > .lisst", 11},
+	}
+
+	for _, c := range cases {
+		t.Run(c.query, func(t *testing.T) {
+			_, err := match.Parse(c.query)
+			require.Error(t, err)
+
+			var syntaxErr *match.SyntaxError
+			require.True(t, errors.As(err, &syntaxErr), "error is a SyntaxError")
+			require.Equal(t, c.column, syntaxErr.Column, err.Error())
+		})
+	}
+}
+
+func TestParseQuery(t *testing.T) {
+	tree, source := test.TreeFromFile(t, "../examples/recipe.md")
+
+	matcher := match.MustParse("## Ingredients > ### Buy > .list:first")
+
+	found, err := query.QueryOne(tree, source, matcher)
+	require.NoError(t, err)
+	require.Equal(t, ast.KindList, found.Kind())
+}
@@ -0,0 +1,235 @@
+// Package mdcallout adds support for Obsidian and GitHub style callouts to goldmark,
+// which are blockquotes that start with a type like > [!note] Title.
+package mdcallout
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+
+	"github.com/will-wow/larkdown/gmast"
+)
+
+// Kind is the kind of callout AST nodes.
+var Kind = ast.NewNodeKind("Callout")
+
+// Fold is whether a callout can be folded, and if it starts folded.
+type Fold byte
+
+const (
+	// FoldNone is a callout that can't be folded, like > [!note].
+	FoldNone Fold = 0
+	// FoldOpen is a foldable callout that starts expanded, like > [!note]+.
+	FoldOpen Fold = '+'
+	// FoldClosed is a foldable callout that starts collapsed, like > [!note]-.
+	FoldClosed Fold = '-'
+)
+
+// Node is a callout in a goldmark Markdown document.
+// Its children are the blocks in the callout after the first line.
+// Its only line is the first line, with the type and title.
+type Node struct {
+	ast.BaseBlock
+
+	// CalloutType is the type of callout as it was written, like "note" or "WARNING".
+	CalloutType []byte
+	// Title is the text after the type on the first line, or nil if there isn't any.
+	Title []byte
+	// Fold is whether the callout can be folded, and if it starts folded.
+	Fold Fold
+}
+
+var _ ast.Node = &Node{}
+
+// Kind reports the kind of callout nodes.
+func (*Node) Kind() ast.NodeKind { return Kind }
+
+// Dump dumps the contents of Node to stdout for debugging.
+func (n *Node) Dump(src []byte, level int) {
+	fold := ""
+	if n.Fold != FoldNone {
+		fold = string(n.Fold)
+	}
+	ast.DumpHelper(n, src, level, map[string]string{
+		"Type":  string(n.CalloutType),
+		"Title": string(n.Title),
+		"Fold":  fold,
+	}, nil)
+}
+
+// parseHeader parses the first line of a callout, like [!note]- Title.
+func parseHeader(line []byte) (n *Node, ok bool) {
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte("[!")) {
+		return nil, false
+	}
+
+	end := bytes.IndexByte(line, ']')
+	if end == -1 {
+		return nil, false
+	}
+	calloutType := line[len("[!"):end]
+	if len(calloutType) == 0 || bytes.ContainsAny(calloutType, " \t[") {
+		return nil, false
+	}
+
+	n = &Node{CalloutType: calloutType}
+	rest := line[end+1:]
+	if len(rest) > 0 && (rest[0] == byte(FoldOpen) || rest[0] == byte(FoldClosed)) {
+		n.Fold = Fold(rest[0])
+		rest = rest[1:]
+	}
+
+	// The title must be separated from the type.
+	if len(rest) > 0 && rest[0] != ' ' && rest[0] != '\t' {
+		return nil, false
+	}
+	if title := bytes.TrimSpace(rest); len(title) > 0 {
+		n.Title = title
+	}
+	return n, true
+}
+
+// astTransformer replaces blockquotes that start with a callout type with callout nodes.
+type astTransformer struct{}
+
+// Transform replaces callout blockquotes with callout nodes.
+func (a *astTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	quotes := []*ast.Blockquote{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if quote, ok := n.(*ast.Blockquote); ok && entering {
+			quotes = append(quotes, quote)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, quote := range quotes {
+		transformCallout(quote, reader.Source())
+	}
+}
+
+// transformCallout replaces a blockquote with a callout, if its first line is a callout type.
+func transformCallout(quote *ast.Blockquote, source []byte) {
+	paragraph, ok := quote.FirstChild().(*ast.Paragraph)
+	if !ok || paragraph.Lines().Len() == 0 {
+		return
+	}
+
+	header := paragraph.Lines().At(0)
+	callout, ok := parseHeader(header.Value(source))
+	if !ok {
+		return
+	}
+	callout.Lines().Append(header)
+
+	// Remove the header line from the paragraph, and the paragraph if that was its only line.
+	removeFirstLine(paragraph, header, source)
+	if paragraph.Lines().Len() == 0 {
+		quote.RemoveChild(quote, paragraph)
+	}
+
+	for child := quote.FirstChild(); child != nil; child = quote.FirstChild() {
+		callout.AppendChild(callout, child)
+	}
+	callout.SetBlankPreviousLines(quote.HasBlankPreviousLines())
+	quote.Parent().ReplaceChild(quote.Parent(), quote, callout)
+}
+
+// removeFirstLine removes a paragraph's first line, and the inline nodes on it.
+func removeFirstLine(paragraph *ast.Paragraph, header text.Segment, source []byte) {
+	lines := text.NewSegments()
+	for i := 1; i < paragraph.Lines().Len(); i++ {
+		lines.Append(paragraph.Lines().At(i))
+	}
+	paragraph.SetLines(lines)
+
+	removeHeaderInlines(paragraph, header, source)
+}
+
+// removeHeaderInlines removes the inline nodes on the header line from a node,
+// and reports if it stopped at a node after the header line.
+// Nodes that continue past the header line, like emphasis around a line break, keep their children after it.
+func removeHeaderInlines(parent ast.Node, header text.Segment, source []byte) bool {
+	for child := parent.FirstChild(); child != nil; child = parent.FirstChild() {
+		if start, ok := gmast.InlineStart(child, source); ok && start >= header.Stop {
+			return true
+		}
+		if child.HasChildren() && removeHeaderInlines(child, header, source) {
+			return true
+		}
+
+		parent.RemoveChild(parent, child)
+		if text, ok := child.(*ast.Text); ok && (text.SoftLineBreak() || text.HardLineBreak()) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewTransformer turns blockquotes that start with a callout type into callout nodes.
+func NewTransformer() parser.ASTTransformer {
+	return &astTransformer{}
+}
+
+// HTMLRenderer renders callouts like Obsidian does, as a div with a title and content.
+type HTMLRenderer struct{}
+
+var _ renderer.NodeRenderer = &HTMLRenderer{}
+
+func (r *HTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(Kind, r.renderCallout)
+}
+
+func (r *HTMLRenderer) renderCallout(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		_, _ = w.WriteString("</div>\n</div>\n")
+		return ast.WalkContinue, nil
+	}
+
+	n, _ := node.(*Node)
+	title := n.Title
+	if title == nil {
+		title = n.CalloutType
+	}
+	_, _ = w.WriteString(`<div class="callout" data-callout="`)
+	_, _ = w.Write(util.EscapeHTML(bytes.ToLower(n.CalloutType)))
+	_, _ = w.WriteString("\">\n<div class=\"callout-title\">")
+	_, _ = w.Write(util.EscapeHTML(title))
+	_, _ = w.WriteString("</div>\n<div class=\"callout-content\">\n")
+	return ast.WalkContinue, nil
+}
+
+// Extender extends a goldmark Markdown object with support for parsing callouts,
+// and rendering them as HTML.
+//
+// Install it on your Markdown object upon creation.
+//
+//	goldmark.New(
+//	  goldmark.WithExtensions(
+//	    // ...
+//	    &mdcallout.Extender{},
+//	  ),
+//	  // ...
+//	)
+type Extender struct{}
+
+var _ goldmark.Extender = (*Extender)(nil)
+
+// Extend extends the provided goldmark Markdown object with support for callouts.
+func (e *Extender) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithASTTransformers(
+			util.Prioritized(NewTransformer(), 100),
+		),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&HTMLRenderer{}, 500),
+		),
+	)
+}
@@ -0,0 +1,87 @@
+package mdcallout_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+
+	"github.com/will-wow/larkdown/internal/test"
+	"github.com/will-wow/larkdown/mdcallout"
+)
+
+func TestTransformCallout(t *testing.T) {
+	t.Run("parses the type, fold, and title", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+			> [!warning]- Careful now
+			> Hot pan.
+			>
+			> - oven mitts
+			`, goldmark.WithExtensions(&mdcallout.Extender{}))
+
+		callout, ok := doc.FirstChild().(*mdcallout.Node)
+		require.True(t, ok, "expected a callout node")
+		require.Equal(t, "warning", string(callout.CalloutType))
+		require.Equal(t, "Careful now", string(callout.Title))
+		require.Equal(t, mdcallout.FoldClosed, callout.Fold)
+
+		// The first line is removed from the body.
+		require.Equal(t, 2, callout.ChildCount())
+		require.Equal(t, ast.KindParagraph, callout.FirstChild().Kind())
+		require.Equal(t, "Hot pan.", string(callout.FirstChild().Text(source)))
+		require.Equal(t, ast.KindList, callout.LastChild().Kind())
+	})
+
+	t.Run("parses callouts without a title or body", func(t *testing.T) {
+		doc, _ := test.TreeFromMd(t, `
+			> [!NOTE]
+			`, goldmark.WithExtensions(&mdcallout.Extender{}))
+
+		callout, ok := doc.FirstChild().(*mdcallout.Node)
+		require.True(t, ok, "expected a callout node")
+		require.Equal(t, "NOTE", string(callout.CalloutType))
+		require.Nil(t, callout.Title)
+		require.Equal(t, mdcallout.FoldNone, callout.Fold)
+		require.Equal(t, 0, callout.ChildCount())
+	})
+
+	t.Run("parses nested callouts", func(t *testing.T) {
+		doc, _ := test.TreeFromMd(t, `
+			> [!question] Why?
+			> > [!tip]+ Because
+			> > It is.
+			`, goldmark.WithExtensions(&mdcallout.Extender{}))
+
+		callout, ok := doc.FirstChild().(*mdcallout.Node)
+		require.True(t, ok, "expected a callout node")
+		nested, ok := callout.FirstChild().(*mdcallout.Node)
+		require.True(t, ok, "expected a nested callout node")
+		require.Equal(t, "tip", string(nested.CalloutType))
+		require.Equal(t, mdcallout.FoldOpen, nested.Fold)
+	})
+
+	t.Run("leaves other blockquotes alone", func(t *testing.T) {
+		for _, markdown := range []string{"> quote", "> [!] empty", "> [!note]title", "> [link]"} {
+			doc, _ := test.TreeFromMd(t, markdown, goldmark.WithExtensions(&mdcallout.Extender{}))
+			require.Equal(t, ast.KindBlockquote, doc.FirstChild().Kind(), markdown)
+		}
+	})
+}
+
+func TestRenderCalloutHTML(t *testing.T) {
+	md := goldmark.New(goldmark.WithExtensions(&mdcallout.Extender{}))
+
+	var html bytes.Buffer
+	err := md.Convert([]byte("> [!Tip] Use <salt>\n> To taste.\n"), &html)
+	require.NoError(t, err)
+
+	require.Equal(t, `<div class="callout" data-callout="tip">
+<div class="callout-title">Use &lt;salt&gt;</div>
+<div class="callout-content">
+<p>To taste.</p>
+</div>
+</div>
+`, html.String())
+}
@@ -0,0 +1,112 @@
+// Package mdfield adds support for Dataview-style inline fields to goldmark,
+// like rating:: 5 on its own line, or [rating:: 5] and (rating:: 5) inside text.
+package mdfield
+
+import (
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// Kind is the kind of inline field AST nodes.
+var Kind = ast.NewNodeKind("InlineField")
+
+// Node is an inline field in a goldmark Markdown document.
+// Its children are the field's value.
+type Node struct {
+	ast.BaseInline
+
+	// Key is the name of the field, like "rating" in rating:: 5.
+	Key []byte
+	// Bracket is the opening bracket of a field inside text, '[' or '(', or 0 for a field on its own line.
+	Bracket byte
+	// Separator is the :: after the key and the spaces after it, as they were written.
+	// It is empty for new fields, which are written with one space before the value.
+	Separator []byte
+}
+
+var _ ast.Node = &Node{}
+
+// Kind reports the kind of inline field nodes.
+func (*Node) Kind() ast.NodeKind { return Kind }
+
+// Dump dumps the contents of Node to stdout for debugging.
+func (n *Node) Dump(src []byte, level int) {
+	bracket := ""
+	if n.Bracket != 0 {
+		bracket = string(n.Bracket)
+	}
+	ast.DumpHelper(n, src, level, map[string]string{
+		"Key":     string(n.Key),
+		"Bracket": bracket,
+	}, nil)
+}
+
+// CloseBracket returns the bracket that closes a field inside text, or 0 for a field on its own line.
+func (n *Node) CloseBracket() byte {
+	switch n.Bracket {
+	case '[':
+		return ']'
+	case '(':
+		return ')'
+	}
+	return 0
+}
+
+// HTMLRenderer renders inline fields as a span with the key and value.
+type HTMLRenderer struct{}
+
+var _ renderer.NodeRenderer = &HTMLRenderer{}
+
+func (r *HTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(Kind, r.renderField)
+}
+
+func (r *HTMLRenderer) renderField(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		_, _ = w.WriteString("</span></span>")
+		return ast.WalkContinue, nil
+	}
+
+	n, _ := node.(*Node)
+	_, _ = w.WriteString(`<span class="inline-field"><span class="inline-field-key">`)
+	_, _ = w.Write(util.EscapeHTML(n.Key))
+	_, _ = w.WriteString(`</span> <span class="inline-field-value">`)
+	return ast.WalkContinue, nil
+}
+
+// Extender extends a goldmark Markdown object with support for parsing inline fields,
+// and rendering them as HTML.
+//
+// Install it on your Markdown object upon creation.
+//
+//	goldmark.New(
+//	  goldmark.WithExtensions(
+//	    // ...
+//	    &mdfield.Extender{},
+//	  ),
+//	  // ...
+//	)
+type Extender struct{}
+
+var _ goldmark.Extender = (*Extender)(nil)
+
+// Extend extends the provided goldmark Markdown object with support for inline fields.
+func (e *Extender) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		// Parse bracketed fields before links and wikilinks, since their values may contain either.
+		parser.WithInlineParsers(
+			util.Prioritized(&Parser{}, 198),
+		),
+		parser.WithASTTransformers(
+			util.Prioritized(NewTransformer(), 100),
+		),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&HTMLRenderer{}, 500),
+		),
+	)
+}
@@ -0,0 +1,109 @@
+package mdfield_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+
+	"github.com/will-wow/larkdown/internal/test"
+	"github.com/will-wow/larkdown/mdfield"
+	"github.com/will-wow/larkdown/mdwiki"
+)
+
+// fields collects the key, bracket, and value of each field in a document.
+func fields(doc ast.Node, source []byte) [][]string {
+	found := [][]string{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if field, ok := n.(*mdfield.Node); ok && entering {
+			bracket := ""
+			if field.Bracket != 0 {
+				bracket = string(field.Bracket)
+			}
+			found = append(found, []string{string(field.Key), bracket, string(field.Text(source))})
+		}
+		return ast.WalkContinue, nil
+	})
+	return found
+}
+
+func TestParseFields(t *testing.T) {
+	t.Run("parses fields on their own line", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+			rating:: 5
+			author:: [[Frank Herbert]]
+			Not a field, with a colon: here.
+
+			- pages:: 412
+			- started::
+			`, goldmark.WithExtensions(&mdfield.Extender{}, &mdwiki.Extender{}))
+
+		require.Equal(t, [][]string{
+			{"rating", "", "5"},
+			{"author", "", "Frank Herbert"},
+			{"pages", "", "412"},
+			{"started", "", ""},
+		}, fields(doc, source))
+
+		// Wikilinks in the value are kept.
+		author := doc.FirstChild().FirstChild().NextSibling()
+		require.Equal(t, mdwiki.Kind, author.FirstChild().Kind())
+	})
+
+	t.Run("parses fields in brackets", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+			I felt [mood:: happy (mostly)] after (time:: 2 hours).
+			`, goldmark.WithExtensions(&mdfield.Extender{}))
+
+		require.Equal(t, [][]string{
+			{"mood", "[", "happy (mostly)"},
+			{"time", "(", "2 hours"},
+		}, fields(doc, source))
+	})
+
+	t.Run("leaves other text alone", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+			A [link](/url) and (an aside) with a key :: value.
+
+			`+"`code:: here`"+` and **bold**:: text
+
+			text with std::vector here
+			Use a::b in plain text.
+			`, goldmark.WithExtensions(&mdfield.Extender{}))
+
+		require.Empty(t, fields(doc, source))
+	})
+
+	t.Run("leaves links with field text alone", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+			[see:: docs](http://x)
+			`, goldmark.WithExtensions(&mdfield.Extender{}))
+
+		require.Empty(t, fields(doc, source))
+		require.Equal(t, ast.KindLink, doc.FirstChild().FirstChild().Kind())
+	})
+
+	t.Run("keeps the nodes on later lines out of a field", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+			rating:: 5
+			<http://x.com> after
+			`, goldmark.WithExtensions(&mdfield.Extender{}))
+
+		require.Equal(t, [][]string{{"rating", "", "5"}}, fields(doc, source))
+		field := doc.FirstChild().FirstChild()
+		require.Equal(t, ast.KindAutoLink, field.NextSibling().Kind())
+	})
+}
+
+func TestRenderFieldHTML(t *testing.T) {
+	md := goldmark.New(goldmark.WithExtensions(&mdfield.Extender{}))
+
+	var html bytes.Buffer
+	err := md.Convert([]byte("Rated [rating:: 5]."), &html)
+	require.NoError(t, err)
+
+	require.Equal(t, `<p>Rated <span class="inline-field"><span class="inline-field-key">rating</span> `+
+		`<span class="inline-field-value">5</span></span>.</p>`+"\n", html.String())
+}
@@ -0,0 +1,182 @@
+package mdfield
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+
+	"github.com/will-wow/larkdown/gmast"
+)
+
+// separator separates a field's key from its value.
+var separator = []byte("::")
+
+// splitField splits text like "key:: value" into the key, the separator and spaces after it, and the offset of the value.
+func splitField(line []byte) (key, separatorText []byte, valueStart int, ok bool) {
+	i := bytes.Index(line, separator)
+	if i == -1 {
+		return nil, nil, 0, false
+	}
+
+	key = bytes.TrimSpace(line[:i])
+	if len(key) == 0 || len(key) != len(bytes.TrimLeft(line[:i], " \t")) || bytes.ContainsAny(key, "[]()*`:#<>|") {
+		return nil, nil, 0, false
+	}
+
+	valueStart = i + len(separator)
+	for valueStart < len(line) && (line[valueStart] == ' ' || line[valueStart] == '\t') {
+		valueStart++
+	}
+	return key, line[i:valueStart], valueStart, true
+}
+
+// Parser is an inline parser for fields in brackets, like [rating:: 5] or (rating:: 5).
+type Parser struct{}
+
+var _ parser.InlineParser = (*Parser)(nil)
+
+func (p *Parser) Trigger() []byte {
+	return []byte{'[', '('}
+}
+
+func (p *Parser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	if len(line) == 0 {
+		return nil
+	}
+
+	n := &Node{Bracket: line[0]}
+	closer := n.CloseBracket()
+
+	// Find the matching close bracket, skipping over any nested brackets in the value.
+	end := -1
+	depth := 0
+	for i, c := range line {
+		if c == n.Bracket {
+			depth++
+		} else if c == closer {
+			depth--
+			if depth == 0 {
+				end = i
+				break
+			}
+		}
+	}
+	if end == -1 {
+		return nil
+	}
+	// [key:: value](url) is a link, not a field.
+	if n.Bracket == '[' && end+1 < len(line) && line[end+1] == '(' {
+		return nil
+	}
+
+	key, separatorText, valueStart, ok := splitField(line[1:end])
+	if !ok {
+		return nil
+	}
+	n.Key = key
+	n.Separator = separatorText
+
+	value := line[1+valueStart : end]
+	valueSegment := text.NewSegment(segment.Start+1+valueStart, segment.Start+1+valueStart+len(bytes.TrimRight(value, " \t")))
+	n.AppendChild(n, ast.NewTextSegment(valueSegment))
+	block.Advance(end + 1)
+	return n
+}
+
+// astTransformer turns lines that are fields, like rating:: 5, into field nodes.
+type astTransformer struct{}
+
+// NewTransformer turns lines in paragraphs that are fields, like rating:: 5, into field nodes.
+func NewTransformer() parser.ASTTransformer {
+	return &astTransformer{}
+}
+
+// Transform finds fields on their own lines in paragraphs and text blocks.
+func (a *astTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	blocks := []ast.Node{}
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering && (n.Kind() == ast.KindParagraph || n.Kind() == ast.KindTextBlock) {
+			blocks = append(blocks, n)
+			return ast.WalkSkipChildren, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	for _, block := range blocks {
+		transformLines(block, reader.Source())
+	}
+}
+
+// transformLines wraps the inline nodes of each line that is a field in a field node.
+func transformLines(block ast.Node, source []byte) {
+	lines := block.Lines()
+	child := block.FirstChild()
+	for i := 0; i < lines.Len() && child != nil; i++ {
+		line := lines.At(i)
+
+		// Collect the inline nodes on this line.
+		onLine := []ast.Node{}
+		for ; child != nil; child = child.NextSibling() {
+			if start, ok := gmast.InlineStart(child, source); ok && start >= line.Stop {
+				break
+			}
+			onLine = append(onLine, child)
+
+			// The line ends at a line break, even if the next node's position isn't known.
+			if text, ok := child.(*ast.Text); ok && (text.SoftLineBreak() || text.HardLineBreak()) {
+				child = child.NextSibling()
+				break
+			}
+		}
+		if len(onLine) == 0 {
+			continue
+		}
+
+		wrapField(block, onLine, line, source)
+	}
+}
+
+// wrapField moves the nodes on a line into a field node, if the line starts with a key like rating::.
+// Keys on their own line can't have spaces, so text like "use std::vector" isn't a field.
+// The nodes before the value must all be text, so the key isn't inside a link or code span.
+func wrapField(block ast.Node, onLine []ast.Node, line text.Segment, source []byte) {
+	key, separatorText, valueStart, ok := splitField(line.Value(source))
+	if !ok || bytes.ContainsAny(key, " \t") {
+		return
+	}
+	valueStart += line.Start
+
+	// Skip the text before the value, keeping the last node for its line break.
+	value := onLine
+	for len(value) > 1 {
+		textNode, ok := value[0].(*ast.Text)
+		if !ok || textNode.Segment.Stop > valueStart {
+			break
+		}
+		value = value[1:]
+	}
+
+	// The value may start partway through a text node, but not partway through anything else.
+	if first, ok := value[0].(*ast.Text); ok {
+		if valueStart > first.Segment.Stop {
+			valueStart = first.Segment.Stop
+		}
+		if first.Segment.Start < valueStart {
+			first.Segment = first.Segment.WithStart(valueStart)
+		}
+	} else if start, ok := gmast.InlineStart(value[0], source); !ok || start < valueStart {
+		return
+	}
+
+	n := &Node{Key: key, Separator: separatorText}
+	block.InsertBefore(block, onLine[0], n)
+	for _, node := range onLine {
+		block.RemoveChild(block, node)
+	}
+	for _, node := range value {
+		n.AppendChild(n, node)
+	}
+}
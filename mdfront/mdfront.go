@@ -1,8 +1,10 @@
-// Package mdfront adds support for rendering frontmatter to markdown for goldmark.
+// Package mdfront adds support for parsing and rendering frontmatter to markdown for goldmark.
 package mdfront
 
 import (
 	"fmt"
+	"reflect"
+	"strings"
 
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
@@ -13,33 +15,132 @@ import (
 	"github.com/yuin/goldmark/util"
 )
 
-// Kind is the kind of hashtag AST nodes.
+// Kind is the kind of frontmatter AST nodes.
 var Kind = ast.NewNodeKind("Frontmatter")
 
-// Node is a hashtag node in a Goldmark Markdown document.
+// Node is a frontmatter node in a Goldmark Markdown document.
 type Node struct {
 	ast.BaseInline
 
-	// Frontmatter is a struct with yaml tags for rendering.
+	// Frontmatter is the parsed frontmatter from the document, as a map[string]any.
+	// It is nil if the document had no frontmatter, or it could not be parsed.
 	Frontmatter any
+
+	// Raw is the original frontmatter text between the delimiters, or nil if the document had no frontmatter.
+	// For JSON frontmatter, it is the whole object including the braces.
+	Raw []byte
+	// Delim is the original opening and closing delimiter, like ---, or nil for JSON frontmatter.
+	Delim []byte
+	// Format is the format of the original frontmatter.
+	Format Format
+	// Segment is the location of the whole frontmatter block in the source, including the delimiters.
+	Segment text.Segment
 }
 
 var _ ast.Node = &Node{}
 
-// Kind reports the kind of hashtag nodes.
+// Kind reports the kind of frontmatter nodes.
 func (*Node) Kind() ast.NodeKind { return Kind }
 
-// Kind reports the kind of hashtag nodes.
+// IsRaw reports that frontmatter nodes are raw.
 func (*Node) IsRaw() bool { return true }
 
 // Dump dumps the contents of Node to stdout for debugging.
 func (n *Node) Dump(src []byte, level int) {
 	ast.DumpHelper(n, src, level, map[string]string{
 		"Frontmatter": fmt.Sprintf("%+v", n.Frontmatter),
+		"Format":      n.Format.Name,
+		"Raw":         string(n.Raw),
 	}, nil)
 }
 
-// astTransformer adds a blank frontmatter node for filling with data during markdown rendering.
+// HasFrontmatter reports if the document had frontmatter when it was parsed.
+func (n *Node) HasFrontmatter() bool {
+	return n.Raw != nil
+}
+
+// Decode unmarshals the original frontmatter into a struct or map, using the original format.
+func (n *Node) Decode(dst any) error {
+	if !n.HasFrontmatter() {
+		return fmt.Errorf("document has no frontmatter")
+	}
+
+	return n.Format.Unmarshal(n.Raw, dst)
+}
+
+// Lookup finds the value at a dot-separated key path in the parsed frontmatter, like "author.name".
+// An empty path returns the whole frontmatter.
+func (n *Node) Lookup(path string) (value any, ok bool) {
+	if n.Frontmatter == nil {
+		return nil, false
+	}
+	if path == "" {
+		return n.Frontmatter, true
+	}
+
+	value = n.Frontmatter
+	for _, key := range strings.Split(path, ".") {
+		fields, isMap := value.(map[string]any)
+		if !isMap {
+			return nil, false
+		}
+		value, ok = fields[key]
+		if !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// DecodeKey unmarshals the value at a dot-separated key path into a value, using the original format.
+// An empty path decodes the whole frontmatter, like Decode.
+func (n *Node) DecodeKey(path string, dst any) error {
+	if path == "" {
+		return n.Decode(dst)
+	}
+
+	value, ok := n.Lookup(path)
+	if !ok {
+		return fmt.Errorf("frontmatter has no key %q", path)
+	}
+
+	dstValue := reflect.ValueOf(dst)
+	if dstValue.Kind() != reflect.Pointer || dstValue.IsNil() {
+		return fmt.Errorf("expected a non-nil pointer, got %T", dst)
+	}
+
+	// Round-trip the value through the format, wrapped in a struct,
+	// since formats like TOML can't marshal a bare value.
+	data, err := n.Format.Marshal(map[string]any{"value": value})
+	if err != nil {
+		return err
+	}
+
+	wrapper := reflect.New(reflect.StructOf([]reflect.StructField{{
+		Name: "Value",
+		Type: dstValue.Elem().Type(),
+		Tag:  `yaml:"value" toml:"value" json:"value"`,
+	}}))
+	if err := n.Format.Unmarshal(data, wrapper.Interface()); err != nil {
+		return err
+	}
+
+	dstValue.Elem().Set(wrapper.Elem().Field(0))
+	return nil
+}
+
+// Get returns the frontmatter node from a document parsed with the Extender, or nil if there is none.
+func Get(doc ast.Node) *Node {
+	for child := doc.FirstChild(); child != nil; child = child.NextSibling() {
+		if n, ok := child.(*Node); ok {
+			return n
+		}
+	}
+	return nil
+}
+
+// astTransformer adds a frontmatter node to the document, with any frontmatter from the source,
+// for filling with data during markdown rendering.
 type astTransformer struct {
 }
 
@@ -47,10 +148,23 @@ type astTransformer struct {
 func (a *astTransformer) Transform(doc *gast.Document, reader text.Reader, pc parser.Context) {
 	fontMatterNode := &Node{}
 
+	// The frontmatter block has already been removed from the AST by a block parser, so find it in the source.
+	if block, ok := findFrontmatter(reader.Source()); ok {
+		fontMatterNode.Raw = block.raw
+		fontMatterNode.Delim = block.delim
+		fontMatterNode.Format = block.format
+		fontMatterNode.Segment = text.NewSegment(0, block.stop)
+
+		var data map[string]any
+		if err := block.format.Unmarshal(block.raw, &data); err == nil {
+			fontMatterNode.Frontmatter = data
+		}
+	}
+
 	doc.InsertBefore(doc, doc.FirstChild(), fontMatterNode)
 }
 
-// NewTransformer adds a blank frontmatter node for filling with data during markdown rendering.
+// NewTransformer adds a frontmatter node for filling with data during markdown rendering.
 func NewTransformer() parser.ASTTransformer {
 	return &astTransformer{}
 }
@@ -71,8 +185,11 @@ func renderNothing(w util.BufWriter, source []byte, node ast.Node, entering bool
 	return ast.WalkContinue, nil
 }
 
-// Extender extends a goldmark Markdown object with support for setting
-// up a frontmatter placeholder for later rendering.
+// Extender extends a goldmark Markdown object with support for parsing
+// existing YAML, TOML, or JSON frontmatter, and setting up a frontmatter node for later rendering.
+//
+// It can be used alongside go.abhg.dev/goldmark/frontmatter, which will take
+// precedence when parsing the frontmatter block.
 //
 // Install it on your Markdown object upon creation.
 //
@@ -92,6 +209,10 @@ var _ goldmark.Extender = (*Extender)(nil)
 func (e *Extender) Extend(m goldmark.Markdown) {
 	// Adds a frontmatter node to front the AST, to be rendered.
 	m.Parser().AddOptions(
+		// Remove the frontmatter block from the AST, after go.abhg.dev/goldmark/frontmatter has had a chance to.
+		parser.WithBlockParsers(
+			util.Prioritized(&blockParser{}, 1),
+		),
 		parser.WithASTTransformers(
 			util.Prioritized(NewTransformer(), 0),
 		),
@@ -0,0 +1,233 @@
+package mdfront_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+
+	"github.com/will-wow/larkdown/internal/test"
+	"github.com/will-wow/larkdown/mdfront"
+)
+
+func TestParseFrontmatter(t *testing.T) {
+	type metaData struct {
+		Title string   `yaml:"title" toml:"title"`
+		Tags  []string `yaml:"tags" toml:"tags"`
+	}
+
+	t.Run("yaml", func(t *testing.T) {
+		doc, _ := test.TreeFromMd(t, `---
+title: My Recipe
+tags: [dinner]
+---
+
+# Title
+`, goldmark.WithExtensions(&mdfront.Extender{}))
+
+		node := mdfront.Get(doc)
+		require.NotNil(t, node)
+		require.True(t, node.HasFrontmatter())
+		require.Equal(t, "YAML", node.Format.Name)
+		require.Equal(t, map[string]any{"title": "My Recipe", "tags": []any{"dinner"}}, node.Frontmatter)
+
+		data := metaData{}
+		err := node.Decode(&data)
+		require.NoError(t, err)
+		require.Equal(t, metaData{Title: "My Recipe", Tags: []string{"dinner"}}, data)
+
+		// The frontmatter is not parsed as markdown
+		require.Equal(t, ast.KindHeading, node.NextSibling().Kind())
+		require.Equal(t, 2, doc.ChildCount())
+	})
+
+	t.Run("toml", func(t *testing.T) {
+		doc, _ := test.TreeFromMd(t, `++++
+title = "My Recipe"
+tags = ["dinner"]
+++++
+
+# Title
+`, goldmark.WithExtensions(&mdfront.Extender{}))
+
+		node := mdfront.Get(doc)
+		require.Equal(t, "TOML", node.Format.Name)
+		require.Equal(t, "++++", string(node.Delim))
+
+		data := metaData{}
+		err := node.Decode(&data)
+		require.NoError(t, err)
+		require.Equal(t, metaData{Title: "My Recipe", Tags: []string{"dinner"}}, data)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		doc, _ := test.TreeFromMd(t, `{
+  "title": "My Recipe",
+  "tags": ["dinner"]
+}
+
+# Title
+`, goldmark.WithExtensions(&mdfront.Extender{}))
+
+		node := mdfront.Get(doc)
+		require.Equal(t, "JSON", node.Format.Name)
+		require.Nil(t, node.Delim)
+		require.Equal(t, "{\n  \"title\": \"My Recipe\",\n  \"tags\": [\"dinner\"]\n}\n", string(node.Raw))
+
+		data := metaData{}
+		err := node.Decode(&data)
+		require.NoError(t, err)
+		require.Equal(t, metaData{Title: "My Recipe", Tags: []string{"dinner"}}, data)
+
+		require.Equal(t, ast.KindHeading, node.NextSibling().Kind())
+	})
+
+	t.Run("no frontmatter", func(t *testing.T) {
+		doc, _ := test.TreeFromMd(t, `
+		# Title
+
+		---
+
+		a: b
+		---
+		`, goldmark.WithExtensions(&mdfront.Extender{}))
+
+		node := mdfront.Get(doc)
+		require.NotNil(t, node, "still adds a placeholder")
+		require.False(t, node.HasFrontmatter())
+		require.Nil(t, node.Frontmatter)
+		require.Error(t, node.Decode(&metaData{}))
+
+		require.Equal(t, ast.KindThematicBreak, node.NextSibling().NextSibling().Kind())
+	})
+}
+
+func TestLookup(t *testing.T) {
+	doc, _ := test.TreeFromMd(t, `---
+author:
+  name: Will
+tags: [dinner]
+---
+`, goldmark.WithExtensions(&mdfront.Extender{}))
+
+	node := mdfront.Get(doc)
+
+	value, ok := node.Lookup("author.name")
+	require.True(t, ok)
+	require.Equal(t, "Will", value)
+
+	_, ok = node.Lookup("author.email")
+	require.False(t, ok)
+
+	_, ok = node.Lookup("tags.name")
+	require.False(t, ok, "can't look up a key in a list")
+
+	var tags []string
+	err := node.DecodeKey("tags", &tags)
+	require.NoError(t, err)
+	require.Equal(t, []string{"dinner"}, tags)
+
+	require.Error(t, node.DecodeKey("missing", &tags))
+}
+
+func TestFrontmatterOnlyOnFirstLine(t *testing.T) {
+	doc, source := test.TreeFromMd(t, "\n---\na: b\n---\n", goldmark.WithExtensions(&mdfront.Extender{}))
+
+	require.False(t, mdfront.Get(doc).HasFrontmatter())
+	require.Equal(t, ast.KindThematicBreak, doc.FirstChild().NextSibling().Kind())
+	require.Equal(t, "a: b", string(doc.LastChild().Text(source)))
+}
+
+func TestUnclosedFrontmatter(t *testing.T) {
+	t.Run("thematic break", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, "---\n\n# Title\n\nBody\n", goldmark.WithExtensions(&mdfront.Extender{}))
+
+		require.False(t, mdfront.Get(doc).HasFrontmatter())
+		require.Equal(t, ast.KindThematicBreak, doc.FirstChild().NextSibling().Kind())
+		require.Equal(t, "Body", string(doc.LastChild().Text(source)))
+	})
+
+	t.Run("json", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, "{\n\"title\": 1\n\n# Title\n", goldmark.WithExtensions(&mdfront.Extender{}))
+
+		require.False(t, mdfront.Get(doc).HasFrontmatter())
+		require.Equal(t, ast.KindParagraph, doc.FirstChild().NextSibling().Kind())
+		require.Equal(t, "Title", string(doc.LastChild().Text(source)))
+	})
+}
+
+func TestPatch(t *testing.T) {
+	type patch struct {
+		Slug   string            `yaml:"slug" toml:"slug"`
+		Title  string            `yaml:"title,omitempty" toml:"title,omitempty"`
+		Author map[string]string `yaml:"author,omitempty" toml:"author,omitempty"`
+	}
+
+	t.Run("yaml keeps order, comments, and unknown keys", func(t *testing.T) {
+		doc, _ := test.TreeFromMd(t, `---
+# Maintained by the editor
+title: My Recipe # the display title
+tags:
+  - dinner
+author:
+  name: Will
+  email: will@example.com
+---
+`, goldmark.WithExtensions(&mdfront.Extender{}))
+
+		patched, err := mdfront.Get(doc).Patch(patch{
+			Slug:   "my-recipe",
+			Author: map[string]string{"name": "Wil"},
+		})
+		require.NoError(t, err)
+		require.Equal(t, `# Maintained by the editor
+title: My Recipe # the display title
+tags:
+  - dinner
+author:
+  name: Wil
+  email: will@example.com
+slug: my-recipe
+`, string(patched))
+	})
+
+	t.Run("replaces values and keeps their comments", func(t *testing.T) {
+		doc, _ := test.TreeFromMd(t, "---\ntitle: Old # the display title\n---\n",
+			goldmark.WithExtensions(&mdfront.Extender{}))
+
+		patched, err := mdfront.Get(doc).Patch(map[string]any{"title": "New"})
+		require.NoError(t, err)
+		require.Equal(t, "title: New # the display title\n", string(patched))
+	})
+
+	t.Run("toml keeps unknown keys", func(t *testing.T) {
+		doc, _ := test.TreeFromMd(t, "+++\ntitle = \"My Recipe\"\ndraft = true\n+++\n",
+			goldmark.WithExtensions(&mdfront.Extender{}))
+
+		patched, err := mdfront.Get(doc).Patch(patch{Slug: "my-recipe"})
+		require.NoError(t, err)
+		require.Equal(t, "draft = true\nslug = \"my-recipe\"\ntitle = \"My Recipe\"\n", string(patched))
+	})
+
+	t.Run("must be a map", func(t *testing.T) {
+		doc, _ := test.TreeFromMd(t, "---\ntitle: My Recipe\n---\n", goldmark.WithExtensions(&mdfront.Extender{}))
+
+		_, err := mdfront.Get(doc).Patch([]string{"slug"})
+		require.Error(t, err)
+	})
+}
+
+func TestSetKey(t *testing.T) {
+	doc, _ := test.TreeFromMd(t, "+++\ntitle = \"My Recipe\"\n\n[author]\nname = \"Will\"\n+++\n",
+		goldmark.WithExtensions(&mdfront.Extender{}))
+
+	node := mdfront.Get(doc)
+	err := node.SetKey("author.name", "Wendy")
+	require.NoError(t, err)
+
+	value, ok := node.Lookup("author.name")
+	require.True(t, ok)
+	require.Equal(t, "Wendy", value)
+	require.Equal(t, "title = \"My Recipe\"\n\n[author]\n  name = \"Wendy\"\n", string(node.Raw))
+}
@@ -0,0 +1,313 @@
+package mdfront
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"gopkg.in/yaml.v3"
+)
+
+// Format describes a frontmatter format, and the delimiter that marks it.
+type Format struct {
+	// Name is a human-readable name for the format.
+	Name string
+	// Delim is the character that is repeated at least three times to open and close the frontmatter.
+	// For inline formats, it is the character that opens the frontmatter on its own line.
+	Delim byte
+	// Close is the character that closes inline formats, like JSON, where the delimiters are part of the data.
+	// It is 0 for formats that open and close with the same repeated Delim.
+	Close byte
+	// Unmarshal unmarshals the frontmatter data into a value.
+	Unmarshal func([]byte, any) error
+	// Marshal marshals a value into frontmatter data.
+	Marshal func(any) ([]byte, error)
+}
+
+// YAML is frontmatter delimited by ---
+var YAML = Format{
+	Name:      "YAML",
+	Delim:     '-',
+	Unmarshal: yaml.Unmarshal,
+	Marshal:   marshalYAML,
+}
+
+// TOML is frontmatter delimited by +++
+var TOML = Format{
+	Name:      "TOML",
+	Delim:     '+',
+	Unmarshal: toml.Unmarshal,
+	Marshal:   marshalTOML,
+}
+
+// JSON is frontmatter that is a JSON object, opening with { and closing with } on their own lines.
+var JSON = Format{
+	Name:      "JSON",
+	Delim:     '{',
+	Close:     '}',
+	Unmarshal: json.Unmarshal,
+	Marshal:   marshalJSON,
+}
+
+// Formats are the frontmatter formats that are recognized at the start of a document.
+var Formats = []Format{YAML, TOML, JSON}
+
+// marshalYAML marshals yaml, returning an error instead of panicking on values yaml can't represent, like funcs.
+func marshalYAML(v any) (data []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("cannot marshal yaml: %v", r)
+		}
+	}()
+
+	return yaml.Marshal(v)
+}
+
+func marshalTOML(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func marshalJSON(v any) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Fence wraps frontmatter data in the format's delimiters, for writing back to a document.
+// If delim is empty, the format's delimiter is repeated three times.
+// Inline formats are returned as-is, since the data includes the delimiters.
+func (f Format) Fence(delim []byte, data []byte) []byte {
+	var buf bytes.Buffer
+
+	if f.inline() {
+		buf.Write(data)
+		if !bytes.HasSuffix(data, []byte("\n")) {
+			buf.WriteByte('\n')
+		}
+		return buf.Bytes()
+	}
+
+	if len(delim) == 0 {
+		delim = bytes.Repeat([]byte{f.Delim}, 3)
+	}
+
+	buf.Write(delim)
+	buf.WriteByte('\n')
+	buf.Write(data)
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	buf.Write(delim)
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+// formatForDelim returns the format that opens with a delimiter.
+func formatForDelim(delim byte) (Format, bool) {
+	for _, format := range Formats {
+		if format.Delim == delim {
+			return format, true
+		}
+	}
+	return Format{}, false
+}
+
+// inline reports if the delimiters are part of the data.
+func (f Format) inline() bool {
+	return f.Close != 0
+}
+
+// closeDelim returns the character that closes a format.
+func (f Format) closeDelim() byte {
+	if f.inline() {
+		return f.Close
+	}
+	return f.Delim
+}
+
+// openFrontmatter checks if a line opens a frontmatter block.
+// Inline formats open with a single delimiter, and others with at least three.
+func openFrontmatter(line []byte) (format Format, count int, ok bool) {
+	delim, count := lineDelim(line)
+	if delim == 0 {
+		return format, 0, false
+	}
+
+	format, ok = formatForDelim(delim)
+	if !ok {
+		return format, 0, false
+	}
+	if format.inline() != (count == 1) {
+		return format, 0, false
+	}
+	if !format.inline() && count < 3 {
+		return format, 0, false
+	}
+	return format, count, true
+}
+
+// closesFrontmatter checks if a line closes a frontmatter block.
+func closesFrontmatter(line []byte, format Format, count int) bool {
+	delim, closeCount := lineDelim(line)
+	return delim == format.closeDelim() && closeCount == count
+}
+
+// frontmatterBlock is the location of a frontmatter block at the start of a document.
+type frontmatterBlock struct {
+	format Format
+	// The opening and closing delimiter, like ---, or nil for inline formats.
+	delim []byte
+	// The contents between the delimiters, or the whole block for inline formats.
+	raw []byte
+	// The offset in the source after the closing delimiter line.
+	stop int
+}
+
+// findFrontmatter finds a frontmatter block at the start of the source,
+// using the same rules as the block parser.
+func findFrontmatter(source []byte) (block frontmatterBlock, ok bool) {
+	line, rest := nextLine(source)
+
+	format, count, ok := openFrontmatter(line)
+	if !ok {
+		return block, false
+	}
+
+	block = frontmatterBlock{format: format}
+
+	start := len(source) - len(rest)
+	if format.inline() {
+		start = 0
+	} else {
+		block.delim = bytes.Repeat([]byte{format.Delim}, count)
+	}
+
+	for len(rest) > 0 {
+		offset := len(source) - len(rest)
+		line, rest = nextLine(rest)
+
+		if closesFrontmatter(line, format, count) {
+			if format.inline() {
+				offset = len(source) - len(rest)
+			}
+			block.raw = source[start:offset]
+			block.stop = len(source) - len(rest)
+			return block, true
+		}
+	}
+
+	// Without a closing delimiter, the opening line is just markdown, like a thematic break.
+	return frontmatterBlock{}, false
+}
+
+// nextLine splits the source after the first newline.
+func nextLine(source []byte) (line []byte, rest []byte) {
+	i := bytes.IndexByte(source, '\n')
+	if i == -1 {
+		return source, nil
+	}
+	return source[:i+1], source[i+1:]
+}
+
+// lineDelim returns the delimiter character and count for a line made up of
+// only the same character, or 0 if it is not a delimiter line.
+func lineDelim(line []byte) (delim byte, count int) {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+	line = bytes.TrimSuffix(line, []byte("\r"))
+	if len(line) == 0 {
+		return 0, 0
+	}
+
+	delim = line[0]
+	for _, c := range line[1:] {
+		if c != delim {
+			return 0, 0
+		}
+	}
+	return delim, len(line)
+}
+
+// blockParser removes the frontmatter block from the document, so it isn't parsed as markdown.
+// The contents are picked up from the source by the astTransformer.
+type blockParser struct{}
+
+var _ parser.BlockParser = (*blockParser)(nil)
+
+// blockKind is the kind of the temporary node that holds the frontmatter while parsing.
+var blockKind = ast.NewNodeKind("FrontmatterBlock")
+
+// blockNode is a temporary node that holds the frontmatter while parsing, and is removed on close.
+type blockNode struct {
+	ast.BaseBlock
+
+	format Format
+	count  int
+}
+
+func (n *blockNode) Kind() ast.NodeKind { return blockKind }
+
+func (n *blockNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+func (p *blockParser) Trigger() []byte {
+	triggers := make([]byte, len(Formats))
+	for i, format := range Formats {
+		triggers[i] = format.Delim
+	}
+	return triggers
+}
+
+func (p *blockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	// Frontmatter is only allowed on the first line.
+	line, segment := reader.PeekLine()
+	if segment.Start != 0 {
+		return nil, parser.NoChildren
+	}
+
+	format, count, ok := openFrontmatter(line)
+	if !ok {
+		return nil, parser.NoChildren
+	}
+
+	// Leave the lines to the other block parsers if the frontmatter is never closed.
+	if _, ok := findFrontmatter(reader.Source()); !ok {
+		return nil, parser.NoChildren
+	}
+
+	return &blockNode{format: format, count: count}, parser.NoChildren
+}
+
+func (p *blockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n, _ := node.(*blockNode)
+	line, segment := reader.PeekLine()
+
+	if closesFrontmatter(line, n.format, n.count) {
+		reader.Advance(segment.Len())
+		return parser.Close
+	}
+	return parser.Continue | parser.NoChildren
+}
+
+func (p *blockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {
+	parent := node.Parent()
+	parent.RemoveChild(parent, node)
+}
+
+func (p *blockParser) CanInterruptParagraph() bool {
+	return false
+}
+
+func (p *blockParser) CanAcceptIndentedLine() bool {
+	return false
+}
@@ -0,0 +1,191 @@
+package mdfront
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Patch applies data onto the original frontmatter, and returns the new frontmatter in the original format.
+// Keys that are in data replace the original values, nested maps are merged, and any other keys are kept.
+//
+// For YAML, the original key order and comments are preserved. Other formats keep unknown keys,
+// but are re-marshaled in the format's default order.
+//
+// Fields of a struct are always written, so use omitempty to leave keys unchanged when a field is empty.
+func (n *Node) Patch(data any) ([]byte, error) {
+	if !n.HasFrontmatter() {
+		return nil, fmt.Errorf("document has no frontmatter")
+	}
+
+	if n.Format.Name == YAML.Name {
+		return patchYAML(n.Raw, data)
+	}
+
+	original := map[string]any{}
+	if err := n.Format.Unmarshal(n.Raw, &original); err != nil {
+		return nil, fmt.Errorf("error parsing frontmatter: %w", err)
+	}
+
+	// Round-trip the data through the format, so its keys match the format's struct tags.
+	patchData, err := n.Format.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	patch := map[string]any{}
+	if err := n.Format.Unmarshal(patchData, &patch); err != nil {
+		return nil, err
+	}
+
+	return n.Format.Marshal(mergeMaps(original, patch))
+}
+
+// SetKey patches a value onto the original frontmatter at a dot-separated key path, like "author.name",
+// and updates Raw and Frontmatter to match. An empty path patches the whole frontmatter, like Patch.
+func (n *Node) SetKey(path string, value any) error {
+	data := value
+	if path != "" {
+		keys := strings.Split(path, ".")
+		for i := len(keys) - 1; i >= 0; i-- {
+			data = map[string]any{keys[i]: data}
+		}
+	}
+
+	raw, err := n.Patch(data)
+	if err != nil {
+		return err
+	}
+
+	var frontmatter map[string]any
+	if err := n.Format.Unmarshal(raw, &frontmatter); err != nil {
+		return fmt.Errorf("error parsing patched frontmatter: %w", err)
+	}
+
+	n.Raw = raw
+	n.Frontmatter = frontmatter
+	return nil
+}
+
+// mergeMaps sets the keys of patch onto original, merging nested maps.
+func mergeMaps(original, patch map[string]any) map[string]any {
+	for key, value := range patch {
+		originalMap, originalIsMap := original[key].(map[string]any)
+		patchMap, patchIsMap := value.(map[string]any)
+		if originalIsMap && patchIsMap {
+			original[key] = mergeMaps(originalMap, patchMap)
+			continue
+		}
+		original[key] = value
+	}
+	return original
+}
+
+// patchYAML applies data onto a yaml.Node tree of the original frontmatter, so comments and order are kept.
+func patchYAML(raw []byte, data any) ([]byte, error) {
+	var original yaml.Node
+	if err := yaml.Unmarshal(raw, &original); err != nil {
+		return nil, fmt.Errorf("error parsing frontmatter: %w", err)
+	}
+
+	var patch yaml.Node
+	if err := patch.Encode(data); err != nil {
+		return nil, err
+	}
+	if patch.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("frontmatter patch must be a map or struct, got %s", yamlKindName(patch.Kind))
+	}
+
+	// An empty document has no content to patch, so the patch becomes the document.
+	if original.Kind != yaml.DocumentNode || len(original.Content) == 0 {
+		original = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	root := original.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("frontmatter must be a map to patch, got %s", yamlKindName(root.Kind))
+	}
+	mergeYAMLMappings(root, &patch)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(yamlIndent(raw))
+	if err := encoder.Encode(&original); err != nil {
+		return nil, err
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// mergeYAMLMappings sets the keys of patch onto original, keeping the original keys' comments and position.
+func mergeYAMLMappings(original, patch *yaml.Node) {
+	for i := 0; i+1 < len(patch.Content); i += 2 {
+		key, value := patch.Content[i], patch.Content[i+1]
+
+		originalValue := yamlMappingValue(original, key.Value)
+		if originalValue == nil {
+			original.Content = append(original.Content, key, value)
+			continue
+		}
+
+		if originalValue.Kind == yaml.MappingNode && value.Kind == yaml.MappingNode {
+			mergeYAMLMappings(originalValue, value)
+			continue
+		}
+
+		// Keep comments attached to the original value.
+		value.HeadComment = originalValue.HeadComment
+		value.LineComment = originalValue.LineComment
+		value.FootComment = originalValue.FootComment
+		*originalValue = *value
+	}
+}
+
+// yamlMappingValue returns the value for a key in a mapping node, or nil if there is none.
+func yamlMappingValue(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// yamlIndent guesses the indentation of the original yaml, so nested maps are written back the same way.
+func yamlIndent(raw []byte) int {
+	indent := 0
+	for _, line := range bytes.Split(raw, []byte("\n")) {
+		trimmed := bytes.TrimLeft(line, " ")
+		spaces := len(line) - len(trimmed)
+		if spaces == 0 || len(trimmed) == 0 || trimmed[0] == '#' {
+			continue
+		}
+		if indent == 0 || spaces < indent {
+			indent = spaces
+		}
+	}
+
+	if indent < 2 {
+		return 2
+	}
+	return indent
+}
+
+func yamlKindName(kind yaml.Kind) string {
+	switch kind {
+	case yaml.DocumentNode:
+		return "document"
+	case yaml.SequenceNode:
+		return "list"
+	case yaml.MappingNode:
+		return "map"
+	case yaml.ScalarNode:
+		return "scalar"
+	case yaml.AliasNode:
+		return "alias"
+	}
+	return "empty"
+}
@@ -0,0 +1,61 @@
+package mdrender
+
+import (
+	"fmt"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+
+	"github.com/will-wow/larkdown/internal/kinds"
+)
+
+// FrontmatterError is returned when the frontmatter can't be marshaled or patched,
+// so a document is never rendered without its frontmatter.
+type FrontmatterError struct {
+	// The name of the format the frontmatter was being rendered in.
+	Format string
+	Err    error
+}
+
+func (e *FrontmatterError) Error() string {
+	return fmt.Sprintf("error rendering %s frontmatter: %s", e.Format, e.Err)
+}
+
+func (e *FrontmatterError) Unwrap() error {
+	return e.Err
+}
+
+// UnsupportedNodeError is returned in strict mode when a document has a node that no renderer can render.
+type UnsupportedNodeError struct {
+	// The kind of the node.
+	Kind ast.NodeKind
+	// The node that can't be rendered.
+	Node ast.Node
+}
+
+func (e *UnsupportedNodeError) Error() string {
+	return fmt.Sprintf("no markdown renderer for %s node", e.Kind)
+}
+
+// UnsupportedRenderer registers a function for every node kind that fails with an *UnsupportedNodeError.
+// Install it at a lower priority than every other renderer, so other renderers replace its functions
+// for the kinds they support, and only nodes that nothing else can render fail.
+//
+//	renderer.NewRenderer(renderer.WithNodeRenderers(
+//		util.Prioritized(mdrender.NewRenderer(mdrender.WithStrict()), 100),
+//		util.Prioritized(&mdrender.UnsupportedRenderer{}, math.MaxInt32),
+//	))
+type UnsupportedRenderer struct{}
+
+var _ renderer.NodeRenderer = &UnsupportedRenderer{}
+
+func (r *UnsupportedRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	for _, kind := range kinds.All() {
+		reg.Register(kind, renderUnsupported)
+	}
+}
+
+func renderUnsupported(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	return ast.WalkStop, &UnsupportedNodeError{Kind: node.Kind(), Node: node}
+}
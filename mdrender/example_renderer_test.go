@@ -47,8 +47,8 @@ func ExampleNewRenderer() {
 		goldmark.WithExtensions(
 			// Parse hashtags to they can be matched against.
 			&hashtag.Extender{Variant: hashtag.ObsidianVariant},
-			// Support frontmatter rendering.
-			// This does nothing on its own, but sets up a place to render frontmatter to.
+			// Support frontmatter parsing and rendering.
+			// This parses any existing frontmatter, and sets up a place to render frontmatter to.
 			&mdfront.Extender{},
 		),
 	)
@@ -87,8 +87,8 @@ func ExampleNewRenderer() {
 	// ====
 
 	// Find the title header to use as a slug
-	// In practice, you might want to also pull this frontmatter out of an existing document
-	// using goldmark-meta or goldmark-frontmatter.
+	// Existing frontmatter can be read with mdfront.Get(doc).Decode(&data),
+	// and is re-rendered unchanged if you don't pass WithFrontmatter.
 	title, err := larkdown.Find(doc, source, titleQuery, larkdown.DecodeText)
 	if err != nil {
 		panic(fmt.Errorf("error finding title: %w", err))
@@ -0,0 +1,608 @@
+package mdrender
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/yuin/goldmark/ast"
+	extension_ast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+
+	"github.com/will-wow/larkdown/gmast"
+	"github.com/will-wow/larkdown/mdfront"
+)
+
+// losslessRegisterer wraps each render function to copy unmodified blocks from the original source.
+type losslessRegisterer struct {
+	renderer.NodeRendererFuncRegisterer
+	r *Renderer
+}
+
+func (l losslessRegisterer) Register(kind ast.NodeKind, fn renderer.NodeRendererFunc) {
+	l.NodeRendererFuncRegisterer.Register(kind, l.r.preserveSource(fn))
+}
+
+// preserveSource wraps a render function, to copy the node from the original source
+// if neither it nor its children were modified.
+func (r *Renderer) preserveSource(fn renderer.NodeRendererFunc) renderer.NodeRendererFunc {
+	return func(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+		original := r.original(source)
+
+		start, stop, ok := copyRange(node, original)
+		if !ok {
+			if isCovered(node, original) {
+				return ast.WalkSkipChildren, nil
+			}
+			return fn(w, source, node, entering)
+		}
+
+		if entering {
+			_, _ = w.Write(original[start:stop])
+
+			// A block that isn't followed by another copied block needs its own blank line,
+			// like the rendered blocks have.
+			if needsSeparator(node, original) {
+				_ = w.WriteByte('\n')
+			}
+		}
+		return ast.WalkSkipChildren, nil
+	}
+}
+
+// original returns the source the document was parsed from.
+func (r *Renderer) original(source []byte) []byte {
+	if r.Original != nil {
+		return r.Original
+	}
+	return source
+}
+
+// renderLosslessDocument writes anything in the original source before the first block,
+// unless the frontmatter node will.
+func (r *Renderer) renderLosslessDocument(w util.BufWriter, source []byte, node ast.Node) {
+	if _, ok := node.FirstChild().(*mdfront.Node); ok {
+		return
+	}
+
+	original := r.original(source)
+	_, _ = w.Write(original[:leadingStop(node.FirstChild(), original)])
+}
+
+// renderLosslessFrontmatter writes the original frontmatter and anything else before the first block,
+// or the configured frontmatter followed by the original text after the frontmatter.
+func (r *Renderer) renderLosslessFrontmatter(w util.BufWriter, source []byte, n *mdfront.Node, frontmatter []byte) {
+	original := r.original(source)
+	stop := leadingStop(n.NextSibling(), original)
+
+	if frontmatter == nil {
+		_, _ = w.Write(original[:stop])
+		return
+	}
+
+	_, _ = w.Write(frontmatter)
+	if n.HasFrontmatter() && n.Segment.Stop <= stop {
+		_, _ = w.Write(original[n.Segment.Stop:stop])
+	} else {
+		_ = w.WriteByte('\n')
+		_, _ = w.Write(original[:stop])
+	}
+}
+
+// losslessIndent returns the original indentation of another item in a list, so new items line up with it.
+func (r *Renderer) losslessIndent(item ast.Node, source []byte) ([]byte, bool) {
+	if !r.Lossless {
+		return nil, false
+	}
+	original := r.original(source)
+
+	for sibling := item.Parent().FirstChild(); sibling != nil; sibling = sibling.NextSibling() {
+		if sibling == item {
+			continue
+		}
+		if start, ok := blockStart(sibling, original); ok {
+			line := original[start:]
+			return line[:len(line)-len(bytes.TrimLeft(line, " \t"))], true
+		}
+	}
+	return nil, false
+}
+
+// losslessMarker returns the marker another item in a list was written with, so new items match it.
+func (r *Renderer) losslessMarker(item ast.Node, source []byte) (byte, bool) {
+	if !r.Lossless {
+		return 0, false
+	}
+	original := r.original(source)
+
+	for sibling := item.Parent().FirstChild(); sibling != nil; sibling = sibling.NextSibling() {
+		if sibling == item {
+			continue
+		}
+		if start, ok := blockStart(sibling, original); ok {
+			line := bytes.TrimLeft(original[start:], " \t")
+			if len(line) > 0 && bytes.IndexByte([]byte("-*+"), line[0]) != -1 {
+				return line[0], true
+			}
+		}
+	}
+	return 0, false
+}
+
+// losslessNumber returns the number for a new item in an ordered list, following the numbers of the other items.
+// Lists that repeat a number keep repeating it, and other lists count up from the closest item before the new one.
+func (r *Renderer) losslessNumber(item ast.Node, source []byte) (int, bool) {
+	if !r.Lossless {
+		return 0, false
+	}
+	original := r.original(source)
+
+	index := 0
+	itemIndex := 0
+	numbers := map[int]int{}
+	seen := map[int]bool{}
+	repeated := -1
+	for sibling := item.Parent().FirstChild(); sibling != nil; sibling = sibling.NextSibling() {
+		if sibling == item {
+			itemIndex = index
+		} else if number, ok := itemNumber(sibling, original); ok {
+			numbers[index] = number
+			if seen[number] {
+				repeated = number
+			}
+			seen[number] = true
+		}
+		index++
+	}
+
+	if repeated != -1 {
+		return repeated, true
+	}
+	for i := itemIndex - 1; i >= 0; i-- {
+		if number, ok := numbers[i]; ok {
+			return number + itemIndex - i, true
+		}
+	}
+	for i := itemIndex + 1; i < index; i++ {
+		if number, ok := numbers[i]; ok && number-(i-itemIndex) >= 0 {
+			return number - (i - itemIndex), true
+		}
+	}
+	return 0, false
+}
+
+// itemNumber parses the number an ordered list item was written with in the original source.
+func itemNumber(item ast.Node, original []byte) (int, bool) {
+	start, ok := blockStart(item, original)
+	if !ok {
+		return 0, false
+	}
+
+	list, _ := item.Parent().(*ast.List)
+	line := bytes.TrimLeft(original[start:], " \t")
+	digits := len(line) - len(bytes.TrimLeft(line, "0123456789"))
+	if digits == 0 || digits == len(line) || line[digits] != list.Marker {
+		return 0, false
+	}
+	number, err := strconv.Atoi(string(line[:digits]))
+	if err != nil {
+		return 0, false
+	}
+	return number, true
+}
+
+// leadingStop finds the start of the first block in the original source, starting from a node.
+func leadingStop(node ast.Node, original []byte) int {
+	for ; node != nil; node = node.NextSibling() {
+		if start, ok := blockStart(node, original); ok {
+			return start
+		}
+	}
+	return len(original)
+}
+
+// copyRange returns the range of a block in the original source, if it can be copied as-is.
+// The range runs to the start of the next block, so it includes any blank lines and link reference definitions.
+func copyRange(node ast.Node, original []byte) (start, stop int, ok bool) {
+	if !isCopyable(node) || !isPristine(node, original) {
+		return 0, 0, false
+	}
+
+	start, ok = blockStart(node, original)
+	if !ok {
+		return 0, 0, false
+	}
+
+	if stop, ok := nextBlockStart(node, original); ok && stop >= start {
+		return start, stop, true
+	}
+
+	stop, ok = blockStop(node, original)
+	if !ok || stop < start {
+		return 0, 0, false
+	}
+	return start, stop, true
+}
+
+// nextBlockStart finds the start of the next sibling in the original source,
+// or the end of the document for the last block in it.
+// It fails if there is a modified block without text in between, since its original text can't be skipped.
+func nextBlockStart(node ast.Node, original []byte) (int, bool) {
+	for next := node.NextSibling(); next != nil; next = next.NextSibling() {
+		if start, ok := blockStart(next, original); ok {
+			return start, true
+		}
+		if !isPristine(next, original) {
+			return 0, false
+		}
+	}
+
+	if node.Parent().Kind() == ast.KindDocument {
+		return len(original), true
+	}
+	return 0, false
+}
+
+// isCovered reports if an unmodified block without text was already copied,
+// as part of the range of a block before it.
+func isCovered(node ast.Node, original []byte) bool {
+	if !isCopyable(node) || !isPristine(node, original) {
+		return false
+	}
+
+	for prev := node.PreviousSibling(); prev != nil; prev = prev.PreviousSibling() {
+		if _, ok := blockStart(prev, original); ok {
+			if !isCopyable(prev) || !isPristine(prev, original) {
+				return false
+			}
+			_, ok := nextBlockStart(prev, original)
+			return ok
+		}
+		if !isPristine(prev, original) {
+			return false
+		}
+	}
+
+	// Blocks before the first block with text are copied with the start of the document.
+	return node.Parent().Kind() == ast.KindDocument
+}
+
+// needsSeparator reports if a copied block stops at the end of its own content,
+// and is followed by a block it should be separated from by a blank line.
+func needsSeparator(node ast.Node, original []byte) bool {
+	if node.NextSibling() == nil {
+		return false
+	}
+	// Items in tight lists are only separated by a line break, which they already end with.
+	if list, ok := node.Parent().(*ast.List); ok && list.IsTight {
+		return false
+	}
+
+	_, ok := nextBlockStart(node, original)
+	return !ok
+}
+
+// isCopyable reports if a node is a block that starts on its own line, so it can be copied from the source.
+// Blocks that share a line with a list marker, and the contents of other blocks, are rendered with their parent.
+func isCopyable(node ast.Node) bool {
+	if node.Type() != ast.TypeBlock {
+		return false
+	}
+	if _, ok := node.(*mdfront.Node); ok {
+		return false
+	}
+	// Footnotes are moved to the end of the document, so they are kept where they were in the original instead.
+	if node.Kind() == extension_ast.KindFootnoteList {
+		return false
+	}
+
+	parent := node.Parent()
+	if parent == nil {
+		return false
+	}
+	// Blocks in quotes are copied with their quote, since their lines start with the quote's >.
+	for ancestor := parent; ancestor != nil; ancestor = ancestor.Parent() {
+		if isQuote(ancestor) {
+			return false
+		}
+	}
+
+	switch parent.Kind() {
+	case ast.KindDocument, ast.KindList:
+		return true
+	case ast.KindListItem:
+		return parent.FirstChild() != node
+	}
+	return false
+}
+
+// isPristine reports if a node and its children were not modified or added after parsing.
+func isPristine(node ast.Node, original []byte) bool {
+	pristine := true
+
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		if gmast.IsModified(n) {
+			pristine = false
+			return ast.WalkStop, nil
+		}
+
+		forEachSegment(n, func(start, stop int) {
+			if stop > len(original) {
+				pristine = false
+			}
+		})
+		if !pristine {
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return pristine
+}
+
+// blockStart finds the start of the line a block starts on in the original source.
+// Blocks without text, like thematic breaks, are found by the lines around them.
+func blockStart(node ast.Node, original []byte) (int, bool) {
+	if start, ok := segmentStart(node, original); ok {
+		return start, true
+	}
+	return textlessStart(node, original)
+}
+
+// segmentStart finds the start of the line of the first original segment in a block.
+func segmentStart(node ast.Node, original []byte) (int, bool) {
+	start := -1
+
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		if code, ok := n.(*ast.FencedCodeBlock); ok {
+			// The opening fence is on the line before the first line of code, or the info string.
+			if fenceStart, ok := fencedCodeStart(code, original); ok && (start == -1 || fenceStart < start) {
+				start = fenceStart
+			}
+			return ast.WalkSkipChildren, nil
+		}
+
+		forEachSegment(n, func(segmentStart, stop int) {
+			if stop <= len(original) && (start == -1 || segmentStart < start) {
+				start = segmentStart
+			}
+		})
+		return ast.WalkContinue, nil
+	})
+
+	if start == -1 {
+		return 0, false
+	}
+	return lineStart(original, start), true
+}
+
+// blockStop finds the end of the last line of a block in the original source.
+func blockStop(node ast.Node, original []byte) (int, bool) {
+	if stop, ok := segmentStop(node, original); ok {
+		return stop, true
+	}
+
+	// A block without text is a single line.
+	if start, ok := textlessStart(node, original); ok {
+		return start + len(lineAt(original, start)), true
+	}
+	return 0, false
+}
+
+// segmentStop finds the end of the line of the last original segment in a block.
+func segmentStop(node ast.Node, original []byte) (int, bool) {
+	stop := -1
+
+	_ = ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		if code, ok := n.(*ast.FencedCodeBlock); ok {
+			if fenceStop, ok := fencedCodeStop(code, original); ok && fenceStop > stop {
+				stop = fenceStop
+			}
+			return ast.WalkSkipChildren, nil
+		}
+
+		forEachSegment(n, func(_, end int) {
+			if end <= len(original) && end > stop {
+				stop = end
+			}
+		})
+		return ast.WalkContinue, nil
+	})
+
+	if stop == -1 {
+		return 0, false
+	}
+	return lineStop(original, stop), true
+}
+
+// textlessStart finds the line of an unmodified block without any text, like a thematic break.
+// It counts back non-blank lines from the next block with text, one for each block without text before it,
+// and checks that the line it lands on still looks like the block.
+func textlessStart(node ast.Node, original []byte) (int, bool) {
+	if node.Kind() != ast.KindThematicBreak || !isPristine(node, original) {
+		return 0, false
+	}
+
+	// Find the next block with text, and the number of blocks without text before it.
+	stop := -1
+	lines := 1
+	for next := node.NextSibling(); next != nil; next = next.NextSibling() {
+		if start, ok := segmentStart(next, original); ok {
+			stop = start
+			break
+		}
+		if next.Kind() != ast.KindThematicBreak || !isPristine(next, original) {
+			return 0, false
+		}
+		lines++
+	}
+	if stop == -1 {
+		if node.Parent() == nil || node.Parent().Kind() != ast.KindDocument {
+			return 0, false
+		}
+		stop = len(original)
+	}
+
+	// Don't count back into the block with text before this one.
+	floor := 0
+	for prev := node.PreviousSibling(); prev != nil; prev = prev.PreviousSibling() {
+		if prevStop, ok := segmentStop(prev, original); ok {
+			floor = prevStop
+			break
+		}
+	}
+
+	start := stop
+	for lines > 0 {
+		if start <= floor {
+			return 0, false
+		}
+		start = lineStart(original, start-1)
+		if len(bytes.TrimSpace(lineAt(original, start))) > 0 {
+			lines--
+		}
+	}
+
+	if !isThematicBreakLine(lineAt(original, start)) {
+		return 0, false
+	}
+	return start, true
+}
+
+// isThematicBreakLine reports if a line is a thematic break, like *** or - - -.
+func isThematicBreakLine(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || bytes.IndexByte([]byte("-*_"), line[0]) == -1 {
+		return false
+	}
+
+	count := 0
+	for _, c := range line {
+		switch c {
+		case line[0]:
+			count++
+		case ' ', '\t':
+		default:
+			return false
+		}
+	}
+	return count >= 3
+}
+
+// forEachSegment calls fn with the location of each of a node's own lines or text segments.
+func forEachSegment(node ast.Node, fn func(start, stop int)) {
+	switch n := node.(type) {
+	case *ast.Text:
+		fn(n.Segment.Start, n.Segment.Stop)
+		return
+	case *ast.HTMLBlock:
+		if n.HasClosure() {
+			fn(n.ClosureLine.Start, n.ClosureLine.Stop)
+		}
+	}
+
+	if node.Type() != ast.TypeBlock {
+		return
+	}
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		fn(line.Start, line.Stop)
+	}
+}
+
+// fencedCodeStart finds the start of the opening fence of a code block.
+func fencedCodeStart(code *ast.FencedCodeBlock, original []byte) (int, bool) {
+	if code.Info != nil && code.Info.Segment.Stop <= len(original) {
+		return lineStart(original, code.Info.Segment.Start), true
+	}
+
+	if code.Lines().Len() == 0 {
+		return 0, false
+	}
+	first := code.Lines().At(0)
+	if first.Stop > len(original) {
+		return 0, false
+	}
+
+	// Back up to the line before the code.
+	start := lineStart(original, first.Start)
+	if start == 0 {
+		return 0, false
+	}
+	return lineStart(original, start-1), true
+}
+
+// fencedCodeStop finds the end of the closing fence of a code block.
+func fencedCodeStop(code *ast.FencedCodeBlock, original []byte) (int, bool) {
+	stop := -1
+	if code.Info != nil && code.Info.Segment.Stop <= len(original) {
+		stop = code.Info.Segment.Stop
+	}
+	if lines := code.Lines(); lines.Len() > 0 {
+		last := lines.At(lines.Len() - 1)
+		if last.Stop <= len(original) {
+			stop = last.Stop
+		}
+	}
+	if stop == -1 {
+		return 0, false
+	}
+	stop = lineStop(original, stop)
+
+	// Include the closing fence, if there is one.
+	closing := lineStop(original, stop+1)
+	if closing > stop && isFenceLine(original[stop:closing]) {
+		stop = closing
+	}
+	return stop, true
+}
+
+// isFenceLine reports if a line is a code fence.
+func isFenceLine(line []byte) bool {
+	line = bytes.TrimLeft(line, " ")
+	return bytes.HasPrefix(line, []byte("```")) || bytes.HasPrefix(line, []byte("~~~"))
+}
+
+// lineStart finds the start of the line that an offset is on.
+func lineStart(source []byte, offset int) int {
+	if offset > len(source) {
+		offset = len(source)
+	}
+	return bytes.LastIndexByte(source[:offset], '\n') + 1
+}
+
+// lineAt returns the line that starts at an offset, including the newline.
+func lineAt(source []byte, start int) []byte {
+	return source[start:lineStop(source, start+1)]
+}
+
+// lineStop finds the end of the line that ends at or after an offset, including the newline.
+func lineStop(source []byte, offset int) int {
+	if offset >= len(source) {
+		return len(source)
+	}
+	if offset <= 0 {
+		offset = 0
+	} else if source[offset-1] == '\n' {
+		return offset
+	}
+
+	i := bytes.IndexByte(source[offset:], '\n')
+	if i == -1 {
+		return len(source)
+	}
+	return offset + i + 1
+}
@@ -0,0 +1,249 @@
+package mdrender
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/util"
+)
+
+// linkStyle is the syntax a link or image was written with.
+type linkStyle int
+
+const (
+	linkInline linkStyle = iota
+	linkFull
+	linkCollapsed
+	linkShortcut
+)
+
+// linkReference returns what to write after the rendered text of a link or image that was written as a reference link:
+// nothing for a shortcut reference like [text], [] for a collapsed reference like [text][],
+// or the label for a full reference like [text][label].
+// Links written inline, and links whose destination or title no longer match their definition, are written inline.
+func (r *Renderer) linkReference(source []byte, node ast.Node, text string, destination, title []byte) (string, bool) {
+	if r.ParserContext == nil {
+		return "", false
+	}
+
+	style, label := writtenLinkStyle(node, r.original(source))
+	if style == linkInline {
+		return "", false
+	}
+	if style != linkFull {
+		label = []byte(text)
+	}
+
+	ref, ok := r.ParserContext.Reference(util.ToLinkReference(label))
+	if !ok || !bytes.Equal(ref.Destination(), destination) || !bytes.Equal(ref.Title(), title) {
+		return "", false
+	}
+
+	switch style {
+	case linkFull:
+		return "[" + string(label) + "]", true
+	case linkShortcut:
+		if canBeShortcutReference(node, source) {
+			return "", true
+		}
+	}
+	return "[]", true
+}
+
+// writtenLinkStyle finds the syntax a link or image was written with in the original source,
+// from the text after its closing bracket, and the label of a full reference.
+// Links whose text wasn't parsed from the original, like new links, are inline.
+func writtenLinkStyle(node ast.Node, original []byte) (linkStyle, []byte) {
+	stop, ok := linkTextStop(node, original)
+	if !ok {
+		return linkInline, nil
+	}
+
+	// Skip the closing delimiters of emphasis and code spans at the end of the text.
+	for stop < len(original) && bytes.IndexByte([]byte("*_~` "), original[stop]) != -1 {
+		stop++
+	}
+	if stop >= len(original) || original[stop] != ']' {
+		return linkInline, nil
+	}
+
+	rest := original[stop+1:]
+	switch {
+	case bytes.HasPrefix(rest, []byte("(")):
+		return linkInline, nil
+	case bytes.HasPrefix(rest, []byte("[]")):
+		return linkCollapsed, nil
+	case bytes.HasPrefix(rest, []byte("[")):
+		end := bytes.IndexByte(rest, ']')
+		if end == -1 {
+			return linkInline, nil
+		}
+		return linkFull, rest[1:end]
+	}
+	return linkShortcut, nil
+}
+
+// linkTextStop finds where the last text in a link or image ends in the original source.
+func linkTextStop(node ast.Node, original []byte) (int, bool) {
+	switch n := node.LastChild().(type) {
+	case nil, *ast.Link, *ast.Image, *ast.AutoLink:
+		return 0, false
+	case *ast.Text:
+		if n.Segment.Stop > len(original) {
+			return 0, false
+		}
+		return n.Segment.Stop, true
+	case *ast.RawHTML:
+		if n.Segments.Len() == 0 {
+			return 0, false
+		}
+		stop := n.Segments.At(n.Segments.Len() - 1).Stop
+		return stop, stop <= len(original)
+	default:
+		return linkTextStop(n, original)
+	}
+}
+
+// canBeShortcutReference reports if a link can be written as [text], without it joining with the text after it,
+// like a following (url) or [label].
+func canBeShortcutReference(node ast.Node, source []byte) bool {
+	next := node.NextSibling()
+	if next == nil {
+		return true
+	}
+
+	text, ok := next.(*ast.Text)
+	if !ok {
+		return false
+	}
+	value := text.Segment.Value(source)
+	return len(value) == 0 || (value[0] != '(' && value[0] != '[' && value[0] != ':')
+}
+
+// renderReferenceDefinitions writes the link reference definitions at the end of the document.
+func (r *Renderer) renderReferenceDefinitions(w util.BufWriter, source []byte, doc ast.Node) error {
+	references := r.sortedReferences(source)
+	if len(references) == 0 {
+		return nil
+	}
+
+	// Separate the definitions from the last block with a blank line, so they don't continue it.
+	// Blocks at the end of a document don't all end with one, so render the last block again to check.
+	// Definitions leave an empty block behind where they were, so skip those.
+	for last := doc.LastChild(); last != nil; last = last.PreviousSibling() {
+		rendered, err := r.renderToString(source, last)
+		if err != nil {
+			return err
+		}
+		if rendered == "" {
+			continue
+		}
+		if !strings.HasSuffix(rendered, "\n\n") {
+			_ = w.WriteByte('\n')
+		}
+		break
+	}
+
+	for _, ref := range references {
+		_ = w.WriteByte('[')
+		_, _ = w.Write(ref.Label())
+		_, _ = w.WriteString("]: ")
+		_, _ = w.Write(linkDestination(ref.Destination()))
+		if ref.Title() != nil {
+			_ = w.WriteByte(' ')
+			_, _ = w.Write(linkTitle(ref.Title()))
+		}
+		_ = w.WriteByte('\n')
+	}
+	return nil
+}
+
+// sortedReferences returns the references from the parser context in the order their labels first appear in the source,
+// since the parser.Context doesn't keep their order.
+func (r *Renderer) sortedReferences(source []byte) []parser.Reference {
+	original := r.original(source)
+
+	if r.ParserContext == nil {
+		return nil
+	}
+
+	references := r.ParserContext.References()
+	position := func(ref parser.Reference) int {
+		i := bytes.Index(original, []byte("["+string(ref.Label())+"]:"))
+		if i == -1 {
+			return len(original)
+		}
+		return i
+	}
+	sort.SliceStable(references, func(i, j int) bool {
+		a, b := position(references[i]), position(references[j])
+		if a != b {
+			return a < b
+		}
+		return bytes.Compare(references[i].Label(), references[j].Label()) < 0
+	})
+	return references
+}
+
+// writeInlineLink writes the destination and title of an inline link or image, like (url "title").
+func writeInlineLink(w util.BufWriter, destination, title []byte) {
+	_ = w.WriteByte('(')
+	if len(destination) > 0 || title != nil {
+		_, _ = w.Write(linkDestination(destination))
+	}
+	if title != nil {
+		_ = w.WriteByte(' ')
+		_, _ = w.Write(linkTitle(title))
+	}
+	_ = w.WriteByte(')')
+}
+
+// linkDestination returns a link destination, wrapped in <> if it is empty or would otherwise end early.
+func linkDestination(destination []byte) []byte {
+	if len(destination) == 0 || destination[0] == '<' || !balancedParens(destination) ||
+		bytes.ContainsAny(destination, " \t\n") {
+		return append(append([]byte{'<'}, destination...), '>')
+	}
+	return destination
+}
+
+// balancedParens reports if every unescaped ( in a destination is closed by a ).
+func balancedParens(destination []byte) bool {
+	depth := 0
+	for i := 0; i < len(destination); i++ {
+		switch destination[i] {
+		case '\\':
+			i++
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return false
+			}
+		}
+	}
+	return depth == 0
+}
+
+// linkTitle returns a link title in double quotes, escaping any quotes in it
+// that were allowed by the title's original single quotes or parentheses.
+func linkTitle(title []byte) []byte {
+	quoted := []byte{'"'}
+	for i := 0; i < len(title); i++ {
+		c := title[i]
+		if c == '\\' && i+1 < len(title) {
+			quoted = append(quoted, c, title[i+1])
+			i++
+			continue
+		}
+		if c == '"' {
+			quoted = append(quoted, '\\')
+		}
+		quoted = append(quoted, c)
+	}
+	return append(quoted, '"')
+}
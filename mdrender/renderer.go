@@ -1,19 +1,53 @@
 package mdrender
 
 import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
 	"github.com/yuin/goldmark/ast"
+	extension_ast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
 	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/util"
 	"go.abhg.dev/goldmark/hashtag"
-	"gopkg.in/yaml.v3"
 
+	"github.com/will-wow/larkdown/gmast"
+	"github.com/will-wow/larkdown/mdcallout"
+	"github.com/will-wow/larkdown/mdfield"
 	"github.com/will-wow/larkdown/mdfront"
+	"github.com/will-wow/larkdown/mdwiki"
 )
 
 // A Config struct has configuration for the markdown renderer.
 type Config struct {
 	Writer      Writer // Writer is a writer used to write textual contents.
-	Frontmatter any    // Frontmatter is a frontmatter struct to be rendered to yaml.
+	Frontmatter any    // Frontmatter is a frontmatter struct to be rendered.
+	// FrontmatterFormat is the format to render Frontmatter in.
+	// If nil, the format of the original document is used, or YAML if it had none.
+	FrontmatterFormat *mdfront.Format
+	// FrontmatterPatch applies Frontmatter onto the original frontmatter, instead of replacing it.
+	FrontmatterPatch bool
+	// Strict fails rendering with an *UnsupportedNodeError if a document has a node kind
+	// that no renderer has a handler for, instead of silently leaving it out.
+	// It needs an UnsupportedRenderer installed alongside the Renderer, which larkdown.NewNodeRenderer does.
+	Strict bool
+	// Lossless copies blocks that were not modified byte-for-byte from the original source,
+	// and only renders blocks that were changed with gmast.
+	Lossless bool
+	// Original is the source the document was parsed from, before any text was appended by gmast.
+	// If nil, the source passed to Render is used.
+	Original []byte
+	// Style is the markdown syntax to write.
+	Style Style
+	// ParserContext is the parser.Context the document was parsed with, which holds its link reference definitions.
+	// Links that were written as reference links stay reference links, and the definitions are written at the end of the document.
+	// The context belongs to a single document, so a renderer with one should only render that document.
+	ParserContext parser.Context
 }
 
 // NewConfig returns a new Config with defaults.
@@ -74,10 +108,102 @@ func WithFrontmatter(data any) Option {
 	return &withFrontmatter{data: data}
 }
 
+type withFrontmatterPatch struct {
+	data any
+}
+
+var _ Option = (*withFrontmatterPatch)(nil)
+
+func (o *withFrontmatterPatch) SetMarkdownOption(c *Config) {
+	c.Frontmatter = o.data
+	c.FrontmatterPatch = true
+}
+
+// WithFrontmatterPatch records frontmatter data to apply onto the original frontmatter when rendering.
+// Keys that are not in data are kept, and for YAML, so are the original key order and comments.
+// See mdfront.Node.Patch for details.
+func WithFrontmatterPatch(data any) Option {
+	return &withFrontmatterPatch{data: data}
+}
+
+type withStrict struct{}
+
+var _ Option = (*withStrict)(nil)
+
+func (o *withStrict) SetMarkdownOption(c *Config) {
+	c.Strict = true
+}
+
+// WithStrict fails rendering when a document has a node that no renderer supports.
+//
+// larkdown.NewNodeRenderer installs an UnsupportedRenderer to find those nodes.
+// If you add the Renderer to a goldmark renderer yourself, add an UnsupportedRenderer too.
+func WithStrict() Option {
+	return &withStrict{}
+}
+
+type withLossless struct {
+	original []byte
+}
+
+var _ Option = (*withLossless)(nil)
+
+func (o *withLossless) SetMarkdownOption(c *Config) {
+	c.Lossless = true
+	c.Original = o.original
+}
+
+// WithLossless renders a document by copying each block from the original source, unless it was modified
+// or added with gmast, so edits to a file only change the lines that were edited.
+//
+// The original source is the source the document was parsed from, before any gmast.New* calls appended to it.
+func WithLossless(original []byte) Option {
+	return &withLossless{original: original}
+}
+
+type withFrontmatterFormat struct {
+	format mdfront.Format
+}
+
+var _ Option = (*withFrontmatterFormat)(nil)
+
+func (o *withFrontmatterFormat) SetMarkdownOption(c *Config) {
+	c.FrontmatterFormat = &o.format
+}
+
+// WithFrontmatterFormat sets the format to render frontmatter in, like mdfront.TOML.
+func WithFrontmatterFormat(format mdfront.Format) Option {
+	return &withFrontmatterFormat{format: format}
+}
+
+type withParserContext struct {
+	pc parser.Context
+}
+
+var _ Option = (*withParserContext)(nil)
+
+func (o *withParserContext) SetMarkdownOption(c *Config) {
+	c.ParserContext = o.pc
+}
+
+// WithParserContext renders the link reference definitions from the parser.Context a document was parsed with,
+// and keeps links that were written with them as reference links, like [text][ref].
+//
+// goldmark only keeps the definitions in the context, so pass one to the parser with parser.WithContext.
+// Each document has its own context, so build a new renderer for each document instead of reusing one.
+func WithParserContext(pc parser.Context) Option {
+	return &withParserContext{pc: pc}
+}
+
 // A Renderer struct is an implementation of renderer.NodeRenderer that renders
 // nodes as Markdown.
 type Renderer struct {
 	Config
+
+	// funcs holds the render functions, for rendering nodes outside of a goldmark renderer.
+	// It is built once, so a Renderer can be shared between goroutines.
+	funcs     funcRegisterer
+	funcsOnce sync.Once
 }
 
 var _ renderer.NodeRenderer = &Renderer{}
@@ -96,6 +222,26 @@ func NewRenderer(opts ...Option) renderer.NodeRenderer {
 
 // RegisterFuncs implements NodeRenderer.RegisterFuncs .
 func (r *Renderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	for kind, fn := range r.registeredFuncs() {
+		reg.Register(kind, fn)
+	}
+}
+
+// registeredFuncs returns this renderer's functions by kind, building them the first time.
+func (r *Renderer) registeredFuncs() funcRegisterer {
+	r.funcsOnce.Do(func() {
+		r.funcs = funcRegisterer{}
+		r.registerFuncs(r.funcs)
+	})
+	return r.funcs
+}
+
+// registerFuncs registers a render function for each kind of node the renderer supports.
+func (r *Renderer) registerFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	if r.Lossless {
+		reg = losslessRegisterer{NodeRendererFuncRegisterer: reg, r: r}
+	}
+
 	// blocks
 
 	reg.Register(ast.KindDocument, r.renderDocument)
@@ -121,6 +267,31 @@ func (r *Renderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
 	reg.Register(ast.KindText, r.renderText)
 	reg.Register(ast.KindString, r.renderString)
 	reg.Register(hashtag.Kind, r.renderHashtag)
+	reg.Register(mdwiki.Kind, r.renderWikiLink)
+
+	// GFM
+
+	reg.Register(extension_ast.KindTable, r.renderTable)
+	reg.Register(extension_ast.KindTableHeader, r.renderTableRow)
+	reg.Register(extension_ast.KindTableRow, r.renderTableRow)
+	reg.Register(extension_ast.KindTableCell, r.renderTableCell)
+	reg.Register(extension_ast.KindStrikethrough, r.renderStrikethrough)
+	reg.Register(extension_ast.KindTaskCheckBox, r.renderTaskCheckBox)
+
+	// Footnotes and definition lists
+
+	reg.Register(extension_ast.KindFootnoteLink, r.renderFootnoteLink)
+	reg.Register(extension_ast.KindFootnoteBacklink, r.renderNothing)
+	reg.Register(extension_ast.KindFootnoteList, r.renderFootnoteList)
+	reg.Register(extension_ast.KindFootnote, r.renderFootnote)
+	reg.Register(extension_ast.KindDefinitionList, r.renderDefinitionList)
+	reg.Register(extension_ast.KindDefinitionTerm, r.renderDefinitionTerm)
+	reg.Register(extension_ast.KindDefinitionDescription, r.renderDefinitionDescription)
+
+	// Obsidian
+
+	reg.Register(mdcallout.Kind, r.renderCallout)
+	reg.Register(mdfield.Kind, r.renderInlineField)
 
 	// Frontmatter
 	reg.Register(mdfront.Kind, r.renderFrontmatter)
@@ -134,13 +305,36 @@ func (r *Renderer) writeLines(w util.BufWriter, source []byte, n ast.Node) {
 	}
 }
 
+// linesValue returns the text of a block's lines.
+func linesValue(n ast.Node, source []byte) []byte {
+	var buf bytes.Buffer
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		buf.Write(line.Value(source))
+	}
+	return buf.Bytes()
+}
+
 func (r *Renderer) renderDocument(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
-	// nothing to do
+	if entering && r.Lossless {
+		r.renderLosslessDocument(w, source, node)
+	}
+	if !entering && !r.Lossless {
+		// Lossless documents keep the definitions where they were in the original.
+		if err := r.renderReferenceDefinitions(w, source, node); err != nil {
+			return ast.WalkStop, err
+		}
+	}
 	return ast.WalkContinue, nil
 }
 
 func (r *Renderer) renderHeading(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	n, _ := node.(*ast.Heading)
+	if r.Style.HeadingStyle == HeadingSetext && n.Level <= 2 && n.HasChildren() {
+		return r.renderSetextHeading(w, source, n, entering)
+	}
+
 	if entering {
 		for i := 0; i < n.Level; i++ {
 			_ = w.WriteByte('#')
@@ -152,48 +346,140 @@ func (r *Renderer) renderHeading(w util.BufWriter, source []byte, node ast.Node,
 	return ast.WalkContinue, nil
 }
 
+// renderSetextHeading underlines a level 1 or 2 heading.
+func (r *Renderer) renderSetextHeading(w util.BufWriter, source []byte, n *ast.Heading, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	text, err := r.renderChildrenToString(source, n)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
+	underline := byte('=')
+	if n.Level == 2 {
+		underline = '-'
+	}
+	width := utf8.RuneCountInString(text)
+	if width < 3 {
+		width = 3
+	}
+
+	_, _ = w.WriteString(text)
+	_ = w.WriteByte('\n')
+	_, _ = w.Write(bytes.Repeat([]byte{underline}, width))
+	_, _ = w.WriteString("\n\n")
+
+	return ast.WalkSkipChildren, nil
+}
+
 func (r *Renderer) renderBlockquote(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
-	if entering {
-		// For each paragraph:
-		for c := n.FirstChild(); c != nil; c = c.NextSibling() {
-			// For each line in the paragraph:
-			l := c.Lines().Len()
-			for i := 0; i < l; i++ {
-				// Write the line with a > prefix
-				line := c.Lines().At(i)
-				_, _ = w.WriteString("> ")
-				_, _ = w.Write(line.Value(source))
-			}
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	if err := r.renderQuoted(w, source, n, nil); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
+}
 
-			if c.NextSibling() != nil {
-				// Extra blank > line between paragraphs of a quote
-				_, _ = w.WriteString("\n>\n")
-			} else {
-				_ = w.WriteByte('\n')
-			}
+// renderCallout renders a callout as a blockquote, with its type, fold, and title on the first line.
+func (r *Renderer) renderCallout(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n, _ := node.(*mdcallout.Node)
+
+	header := []string{"[!" + string(n.CalloutType) + "]"}
+	if n.Fold != mdcallout.FoldNone {
+		header[0] += string(n.Fold)
+	}
+	if len(n.Title) > 0 {
+		header[0] += " " + string(n.Title)
+	}
+	// Blocks other than paragraphs can't always interrupt the first line, so separate them with a blank line.
+	if first := n.FirstChild(); first != nil && first.Kind() != ast.KindParagraph {
+		header = append(header, "")
+	}
+
+	if err := r.renderQuoted(w, source, n, header); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// renderQuoted renders a blockquote's children, and writes each line with a > prefix, after any header lines.
+// Lines after the first are indented to match the list item the quote is in.
+func (r *Renderer) renderQuoted(w util.BufWriter, source []byte, n ast.Node, header []string) error {
+	contents, err := r.renderChildrenToString(source, n)
+	if err != nil {
+		return err
+	}
+
+	lines := header
+	if contents = strings.TrimRight(contents, "\n"); contents != "" || len(lines) == 0 {
+		lines = append(lines, strings.Split(contents, "\n")...)
+	}
+
+	quoted := make([]string, len(lines))
+	for i, line := range lines {
+		if line == "" {
+			quoted[i] = ">"
+		} else {
+			quoted[i] = "> " + line
 		}
+	}
+	writeBlockLines(w, n, quoted)
+	return nil
+}
 
-		return ast.WalkSkipChildren, nil
-	} else {
+// writeBlockLines writes the lines of a block that are rendered together, like a quote or table.
+// Like a paragraph, the first line is only indented if the block doesn't start the list item it's in.
+func writeBlockLines(w util.BufWriter, n ast.Node, lines []string) {
+	item := n.Parent()
+	inItem := item.Kind() == ast.KindListItem
+	indent := strings.Repeat(" ", listItemIndent(n))
+	for i, line := range lines {
+		if i > 0 {
+			_ = w.WriteByte('\n')
+		}
+		if i > 0 || (inItem && item.FirstChild() != n) {
+			_, _ = w.WriteString(indent)
+		}
+		_, _ = w.WriteString(line)
+	}
+
+	// Blocks are followed by a blank line, so a paragraph after them isn't a lazy continuation.
+	// At the end of a list item, the item writes the line break, like for text blocks and paragraphs.
+	switch {
+	case inItem && item.LastChild() == n:
+		if list, ok := item.Parent().(*ast.List); ok && !list.IsTight {
+			_ = w.WriteByte('\n')
+		}
+	case n.NextSibling() == nil:
 		_ = w.WriteByte('\n')
-		return ast.WalkContinue, nil
+	default:
+		_, _ = w.WriteString("\n\n")
 	}
 }
 
 func (r *Renderer) renderCodeBlock(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	fence := r.Style.codeFence(linesValue(n, source))
 	if entering {
-		_, _ = w.WriteString("```\n")
+		_, _ = w.WriteString(fence + "\n")
 		r.writeLines(w, source, n)
 	} else {
-		_, _ = w.WriteString("```\n\n")
+		_, _ = w.WriteString(fence + "\n\n")
 	}
 	return ast.WalkContinue, nil
 }
 
 func (r *Renderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	n, _ := node.(*ast.FencedCodeBlock)
+	fence := r.Style.codeFence(linesValue(n, source))
 	if entering {
-		_, _ = w.WriteString("```")
+		_, _ = w.WriteString(fence)
 		language := n.Language(source)
 		if language != nil {
 			r.Writer.Write(w, language)
@@ -201,7 +487,7 @@ func (r *Renderer) renderFencedCodeBlock(w util.BufWriter, source []byte, node a
 		_, _ = w.WriteString("\n")
 		r.writeLines(w, source, n)
 	} else {
-		_, _ = w.WriteString("```\n\n")
+		_, _ = w.WriteString(fence + "\n\n")
 	}
 	return ast.WalkContinue, nil
 }
@@ -225,15 +511,23 @@ func (r *Renderer) renderHTMLBlock(w util.BufWriter, source []byte, node ast.Nod
 }
 
 func (r *Renderer) renderList(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
-	if !entering && node.Parent().Kind() == ast.KindDocument && node.NextSibling() != nil {
+	if !entering && (node.Parent().Kind() == ast.KindDocument || isQuote(node.Parent())) && node.NextSibling() != nil {
 		_ = w.WriteByte('\n')
 	}
 	return ast.WalkContinue, nil
 }
 
 func indentListItemChild(w util.BufWriter, node ast.Node) {
+	indent := listItemIndent(node)
+	for i := 0; i < indent; i++ {
+		_ = w.WriteByte(' ')
+	}
+}
+
+// listItemIndent returns the number of spaces that the content of a node's list items is indented by.
+func listItemIndent(node ast.Node) int {
 	indent := 0
-	for node.Parent() != nil {
+	for node.Parent() != nil && !isQuote(node.Parent()) {
 		list, ok := node.Parent().(*ast.List)
 		if ok {
 			if list.IsOrdered() {
@@ -244,10 +538,13 @@ func indentListItemChild(w util.BufWriter, node ast.Node) {
 		}
 		node = node.Parent()
 	}
+	return indent
+}
 
-	for i := 0; i < indent; i++ {
-		_ = w.WriteByte(' ')
-	}
+// isQuote reports if a node is a blockquote or callout, whose lines are all prefixed with >.
+// The contents of a quote are indented from the start of the quote, rather than the start of the line.
+func isQuote(node ast.Node) bool {
+	return node.Kind() == ast.KindBlockquote || node.Kind() == mdcallout.Kind
 }
 
 func (r *Renderer) renderListItem(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
@@ -256,12 +553,24 @@ func (r *Renderer) renderListItem(w util.BufWriter, source []byte, n ast.Node, e
 	if entering {
 		ordered := list.IsOrdered()
 
-		// Add indent for each parent list.
-		indentListItemChild(w, list)
+		// Add indent for each parent list, matching the other items when preserving the source.
+		if indent, ok := r.losslessIndent(n, source); ok {
+			_, _ = w.Write(indent)
+		} else {
+			indentListItemChild(w, list)
+		}
 
 		if ordered {
-			_ = w.WriteByte('1')
+			number, ok := r.losslessNumber(n, source)
+			if !ok {
+				number = r.listItemNumber(list, n)
+			}
+			_, _ = w.WriteString(strconv.Itoa(number))
 			_ = w.WriteByte(list.Marker)
+		} else if marker, ok := r.losslessMarker(n, source); ok {
+			_ = w.WriteByte(marker)
+		} else if r.Style.BulletMarker != 0 {
+			_ = w.WriteByte(r.Style.BulletMarker)
 		} else {
 			_ = w.WriteByte(list.Marker)
 		}
@@ -276,6 +585,19 @@ func (r *Renderer) renderListItem(w util.BufWriter, source []byte, n ast.Node, e
 	return ast.WalkContinue, nil
 }
 
+// listItemNumber returns the number to write for an ordered list item.
+func (r *Renderer) listItemNumber(list *ast.List, item ast.Node) int {
+	if r.Style.OrderedNumbering != NumberingIncrement {
+		return list.Start
+	}
+
+	index := 0
+	for sibling := list.FirstChild(); sibling != nil && sibling != item; sibling = sibling.NextSibling() {
+		index++
+	}
+	return list.Start + index
+}
+
 func listItemLineBreak(n ast.Node, isTight bool) bool {
 	// Skip adding a newline if the last child is a list (since it already does that).
 	lc := n.LastChild()
@@ -299,6 +621,9 @@ func (r *Renderer) renderParagraph(w util.BufWriter, source []byte, n ast.Node,
 		if n.Parent().Kind() == ast.KindListItem && n.Parent().FirstChild() != n {
 			indentListItemChild(w, n)
 		}
+		if r.Style.WrapWidth > 0 {
+			return r.renderWrapped(w, source, n)
+		}
 	} else {
 		if n.Parent().LastChild() == n {
 			_ = w.WriteByte('\n')
@@ -311,14 +636,35 @@ func (r *Renderer) renderParagraph(w util.BufWriter, source []byte, n ast.Node,
 }
 
 func (r *Renderer) renderTextBlock(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering && r.Style.WrapWidth > 0 {
+		return r.renderWrapped(w, source, n)
+	}
 	if !entering {
 		if n.NextSibling() != nil && n.FirstChild() != nil {
 			_ = w.WriteByte('\n')
+			// A tight definition description only makes its first paragraph a text block,
+			// so the paragraphs after it still need a blank line.
+			if n.NextSibling().Kind() == ast.KindParagraph {
+				_ = w.WriteByte('\n')
+			}
 		}
 	}
 	return ast.WalkContinue, nil
 }
 
+// renderWrapped writes a paragraph's contents wrapped to the style's width,
+// with wrapped lines indented to match the list item the paragraph is in.
+func (r *Renderer) renderWrapped(w util.BufWriter, source []byte, n ast.Node) (ast.WalkStatus, error) {
+	text, err := r.renderChildrenToString(source, n)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+
+	indent := strings.Repeat(" ", listItemIndent(n))
+	_, _ = w.WriteString(wrapText(strings.TrimSuffix(text, "\n"), r.Style.WrapWidth, indent))
+	return ast.WalkSkipChildren, nil
+}
+
 func (r *Renderer) renderThematicBreak(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
 	if !entering {
 		return ast.WalkContinue, nil
@@ -333,48 +679,176 @@ func (r *Renderer) renderAutoLink(w util.BufWriter, source []byte, node ast.Node
 		return ast.WalkContinue, nil
 	}
 
-	url := n.URL(source)
+	label := n.Label(source)
+
+	// Links found by the linkify extension are written bare, as they were in the source.
+	if !isBracketedAutoLink(source, n) {
+		_, _ = w.Write(label)
+		return ast.WalkContinue, nil
+	}
 
 	_, _ = w.WriteString("<")
-	// TODO: Labels?
-	// w.Write(label)
-	_, _ = w.Write(url)
+	_, _ = w.Write(label)
 	_, _ = w.WriteString(">")
 
 	return ast.WalkContinue, nil
 }
 
+// isBracketedAutoLink checks if an autolink's label was wrapped in <> in the source.
+// Autolinks without brackets come from the linkify extension.
+// Autolinks that can't be found in the source, like new ones, are always bracketed.
+func isBracketedAutoLink(source []byte, n *ast.AutoLink) bool {
+	start, ok := gmast.InlineStart(n, source)
+	if !ok || start == 0 {
+		return true
+	}
+
+	return source[start-1] == '<'
+}
+
 func (r *Renderer) renderCodeSpan(w util.BufWriter, source []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
-	// TODO: somehow get the number of backticks, which isn't in the AST.
-	// Write a ` on entering and leaving
-	_ = w.WriteByte('`')
+	if !entering {
+		return ast.WalkContinue, nil
+	}
 
-	return ast.WalkContinue, nil
+	var code []byte
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch child := c.(type) {
+		case *ast.Text:
+			code = append(code, child.Segment.Value(source)...)
+		case *ast.String:
+			code = append(code, child.Value...)
+		}
+	}
+
+	fence := codeSpanFence(code)
+	padding := ""
+	if codeSpanNeedsPadding(code) {
+		padding = " "
+	}
+
+	_, _ = w.WriteString(fence + padding)
+	r.Writer.Write(w, code)
+	_, _ = w.WriteString(padding + fence)
+
+	return ast.WalkSkipChildren, nil
+}
+
+// codeSpanFence returns the shortest run of backticks that doesn't appear in the code,
+// so the code span isn't closed early.
+func codeSpanFence(code []byte) string {
+	runs := map[int]bool{}
+	for i := 0; i < len(code); i++ {
+		if code[i] != '`' {
+			continue
+		}
+		run := 1
+		for i+run < len(code) && code[i+run] == '`' {
+			run++
+		}
+		runs[run] = true
+		i += run - 1
+	}
+
+	length := 1
+	for runs[length] {
+		length++
+	}
+	return strings.Repeat("`", length)
+}
+
+// codeSpanNeedsPadding reports if code needs a space inside each fence.
+// Code that starts or ends with a backtick would join the fence,
+// and code that starts and ends with a space would have a space stripped from each end when parsed.
+func codeSpanNeedsPadding(code []byte) bool {
+	if len(code) == 0 {
+		return false
+	}
+	if code[0] == '`' || code[len(code)-1] == '`' {
+		return true
+	}
+	return isCodeSpanSpace(code[0]) && isCodeSpanSpace(code[len(code)-1]) &&
+		len(bytes.Trim(code, " \n")) > 0
+}
+
+func isCodeSpanSpace(c byte) bool {
+	return c == ' ' || c == '\n'
 }
 
 func (r *Renderer) renderEmphasis(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	n, _ := node.(*ast.Emphasis)
-	tag := "_"
-	// TODO: Handle nested emphasis
-	if n.Level == 2 {
-		tag = "**"
-	}
+	tag := strings.Repeat(string(r.emphasisDelimiter(n, source)), n.Level)
 
-	// Write * on entering and leaving
+	// Write the delimiter on entering and leaving
 	_, _ = w.WriteString(tag)
 	return ast.WalkContinue, nil
 }
 
+// emphasisDelimiter picks the delimiter character for an emphasis node, so it parses back the same way.
+// Emphasis inside a word must use *, since _ doesn't work there,
+// and nested emphasis alternates between * and _, so the delimiters of the two don't merge, like ***both***.
+func (r *Renderer) emphasisDelimiter(n *ast.Emphasis, source []byte) byte {
+	delimiter := r.Style.emphasisMarker()
+	if n.Level == 2 {
+		delimiter = r.Style.strongMarker()
+	}
+
+	if isIntraword(n, source) {
+		return '*'
+	}
+
+	for parent := n.Parent(); parent != nil; parent = parent.Parent() {
+		if emphasis, ok := parent.(*ast.Emphasis); ok {
+			if r.emphasisDelimiter(emphasis, source) == delimiter {
+				return otherEmphasisDelimiter(delimiter)
+			}
+			break
+		}
+	}
+	return delimiter
+}
+
+func otherEmphasisDelimiter(delimiter byte) byte {
+	if delimiter == '*' {
+		return '_'
+	}
+	return '*'
+}
+
+// isIntraword reports if a node is directly next to a letter or digit in the text around it.
+func isIntraword(n ast.Node, source []byte) bool {
+	if prev, ok := n.PreviousSibling().(*ast.Text); ok && !prev.SoftLineBreak() && !prev.HardLineBreak() {
+		r, _ := utf8.DecodeLastRune(prev.Segment.Value(source))
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	if next, ok := n.NextSibling().(*ast.Text); ok {
+		r, _ := utf8.DecodeRune(next.Segment.Value(source))
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return true
+		}
+	}
+	return false
+}
+
 func (r *Renderer) renderLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	n, _ := node.(*ast.Link)
 
 	if entering {
 		_, _ = w.WriteString("[")
+		return ast.WalkContinue, nil
+	}
+
+	_, _ = w.WriteString("]")
+	text, err := r.renderChildrenToString(source, n)
+	if err != nil {
+		return ast.WalkStop, err
+	}
+	if reference, ok := r.linkReference(source, n, text, n.Destination, n.Title); ok {
+		_, _ = w.WriteString(reference)
 	} else {
-		_, _ = w.WriteString("]")
-		_, _ = w.WriteString("(")
-		_, _ = w.Write(n.Destination)
-		_, _ = w.WriteString(")")
+		writeInlineLink(w, n.Destination, n.Title)
 	}
 	return ast.WalkContinue, nil
 }
@@ -385,18 +859,19 @@ func (r *Renderer) renderImage(w util.BufWriter, source []byte, node ast.Node, e
 	}
 	n, _ := node.(*ast.Image)
 
+	text, err := r.renderChildrenToString(source, n)
+	if err != nil {
+		return ast.WalkStop, err
+	}
 	_, _ = w.WriteString("![")
-	_, _ = w.Write(n.Text(source))
-	_, _ = w.WriteString("](")
-	_, _ = w.Write(n.Destination)
+	_, _ = w.WriteString(text)
+	_, _ = w.WriteString("]")
 
-	if n.Title != nil {
-		// If there's a title, write it in quotes.
-		_, _ = w.WriteString(` "`)
-		_, _ = w.Write(n.Title)
-		_ = w.WriteByte('"')
+	if reference, ok := r.linkReference(source, n, text, n.Destination, n.Title); ok {
+		_, _ = w.WriteString(reference)
+	} else {
+		writeInlineLink(w, n.Destination, n.Title)
 	}
-	_ = w.WriteByte(')')
 	return ast.WalkSkipChildren, nil
 }
 
@@ -447,29 +922,468 @@ func (r *Renderer) renderHashtag(w util.BufWriter, source []byte, node ast.Node,
 	return ast.WalkContinue, nil
 }
 
-// renderFrontmatter renders the configured frontmatter back to yaml.
+// renderWikiLink renders a wikilink from its parts, so edits to the target, heading, or alias are kept.
+func (r *Renderer) renderWikiLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n, _ := node.(*mdwiki.Node)
+
+	if n.Embed {
+		_ = w.WriteByte('!')
+	}
+	_, _ = w.WriteString("[[")
+	_, _ = w.Write(n.Target)
+	if len(n.Heading) > 0 {
+		_ = w.WriteByte('#')
+		_, _ = w.Write(n.Heading)
+	}
+	if len(n.Alias) > 0 {
+		_ = w.WriteByte('|')
+		_, _ = w.Write(n.Alias)
+	}
+	_, _ = w.WriteString("]]")
+	return ast.WalkSkipChildren, nil
+}
+
+// renderInlineField renders the key of an inline field, and its brackets around the value.
+func (r *Renderer) renderInlineField(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n, _ := node.(*mdfield.Node)
+	if !entering {
+		if closer := n.CloseBracket(); closer != 0 {
+			_ = w.WriteByte(closer)
+		}
+		return ast.WalkContinue, nil
+	}
+
+	if n.Bracket != 0 {
+		_ = w.WriteByte(n.Bracket)
+	}
+	_, _ = w.Write(n.Key)
+	if n.Separator != nil {
+		_, _ = w.Write(n.Separator)
+		return ast.WalkContinue, nil
+	}
+	_, _ = w.WriteString("::")
+	if len(n.Text(source)) > 0 {
+		_ = w.WriteByte(' ')
+	}
+	return ast.WalkContinue, nil
+}
+
+func (r *Renderer) renderTable(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n, _ := node.(*extension_ast.Table)
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+
+	// Render every cell first, so the columns can be padded to the same width.
+	rows := [][]string{}
+	for row := n.FirstChild(); row != nil; row = row.NextSibling() {
+		cells := []string{}
+		for cell := row.FirstChild(); cell != nil; cell = cell.NextSibling() {
+			contents, err := r.renderChildrenToString(source, cell)
+			if err != nil {
+				return ast.WalkStop, err
+			}
+			cells = append(cells, escapeTablePipes(contents))
+		}
+		rows = append(rows, cells)
+	}
+
+	columns := len(n.Alignments)
+	for _, cells := range rows {
+		if len(cells) > columns {
+			columns = len(cells)
+		}
+	}
+
+	// The delimiter row needs at least 3 dashes.
+	widths := make([]int, columns)
+	for i := range widths {
+		widths[i] = 3
+	}
+	for _, cells := range rows {
+		for i, cell := range cells {
+			if width := utf8.RuneCountInString(cell); width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+
+	lines := []string{}
+	for i, cells := range rows {
+		lines = append(lines, tableRow(cells, widths, n.Alignments))
+
+		// Write the delimiter row after the header.
+		if i == 0 {
+			lines = append(lines, tableDelimiterRow(widths, n.Alignments))
+		}
+	}
+	writeBlockLines(w, n, lines)
+
+	return ast.WalkSkipChildren, nil
+}
+
+// renderTableRow does nothing, since rows are written by renderTable.
+func (r *Renderer) renderTableRow(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	return ast.WalkContinue, nil
+}
+
+// renderTableCell does nothing, since cells are written by renderTable.
+func (r *Renderer) renderTableCell(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	return ast.WalkContinue, nil
+}
+
+// tableRow returns a row of cells, padded to the column widths.
+func tableRow(cells []string, widths []int, alignments []extension_ast.Alignment) string {
+	var row strings.Builder
+	row.WriteByte('|')
+	for i, width := range widths {
+		cell := ""
+		if i < len(cells) {
+			cell = cells[i]
+		}
+
+		padding := width - utf8.RuneCountInString(cell)
+		left, right := 0, padding
+		switch alignmentAt(alignments, i) {
+		case extension_ast.AlignRight:
+			left, right = padding, 0
+		case extension_ast.AlignCenter:
+			left = padding / 2
+			right = padding - left
+		}
+
+		row.WriteByte(' ')
+		row.WriteString(strings.Repeat(" ", left))
+		row.WriteString(cell)
+		row.WriteString(strings.Repeat(" ", right))
+		row.WriteString(" |")
+	}
+	return row.String()
+}
+
+// tableDelimiterRow returns the row of dashes under the header, with alignment markers.
+func tableDelimiterRow(widths []int, alignments []extension_ast.Alignment) string {
+	var row strings.Builder
+	row.WriteByte('|')
+	for i, width := range widths {
+		alignment := alignmentAt(alignments, i)
+
+		dashes := width
+		left, right := false, false
+		switch alignment {
+		case extension_ast.AlignLeft:
+			left = true
+		case extension_ast.AlignRight:
+			right = true
+		case extension_ast.AlignCenter:
+			left, right = true, true
+		}
+
+		row.WriteByte(' ')
+		if left {
+			row.WriteByte(':')
+			dashes--
+		}
+		if right {
+			dashes--
+		}
+		row.WriteString(strings.Repeat("-", dashes))
+		if right {
+			row.WriteByte(':')
+		}
+		row.WriteString(" |")
+	}
+	return row.String()
+}
+
+func alignmentAt(alignments []extension_ast.Alignment, index int) extension_ast.Alignment {
+	if index < len(alignments) {
+		return alignments[index]
+	}
+	return extension_ast.AlignNone
+}
+
+// escapeTablePipes escapes any pipes in a cell that are not already escaped, so they don't end the cell.
+func escapeTablePipes(cell string) string {
+	var out strings.Builder
+	for i := 0; i < len(cell); i++ {
+		if cell[i] == '|' && (i == 0 || cell[i-1] != '\\') {
+			out.WriteByte('\\')
+		}
+		out.WriteByte(cell[i])
+	}
+	return out.String()
+}
+
+// renderChildrenToString renders the children of a node with this renderer's functions,
+// for layouts like tables that need to measure their contents before writing them.
+func (r *Renderer) renderChildrenToString(source []byte, node ast.Node) (string, error) {
+	var nodes []ast.Node
+	for child := node.FirstChild(); child != nil; child = child.NextSibling() {
+		nodes = append(nodes, child)
+	}
+	return r.renderToString(source, nodes...)
+}
+
+// renderToString renders nodes with this renderer's functions.
+func (r *Renderer) renderToString(source []byte, nodes ...ast.Node) (string, error) {
+	funcs := r.registeredFuncs()
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+
+	for _, node := range nodes {
+		err := ast.Walk(node, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			fn, ok := funcs[n.Kind()]
+			if !ok {
+				// Other renderers' functions aren't available here, so strict mode can't render the node.
+				if r.Strict && entering {
+					return ast.WalkStop, &UnsupportedNodeError{Kind: n.Kind(), Node: n}
+				}
+				return ast.WalkContinue, nil
+			}
+			return fn(w, source, n, entering)
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// funcRegisterer records render functions by kind, for rendering nodes outside of a goldmark renderer.
+type funcRegisterer map[ast.NodeKind]renderer.NodeRendererFunc
+
+var _ renderer.NodeRendererFuncRegisterer = funcRegisterer{}
+
+func (f funcRegisterer) Register(kind ast.NodeKind, fn renderer.NodeRendererFunc) {
+	f[kind] = fn
+}
+
+func (r *Renderer) renderStrikethrough(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	// Write ~~ on entering and leaving
+	_, _ = w.WriteString("~~")
+	return ast.WalkContinue, nil
+}
+
+func (r *Renderer) renderTaskCheckBox(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n, _ := node.(*extension_ast.TaskCheckBox)
+
+	// The text after the checkbox does not include the space.
+	if n.IsChecked {
+		_, _ = w.WriteString("[x] ")
+	} else {
+		_, _ = w.WriteString("[ ] ")
+	}
+	return ast.WalkContinue, nil
+}
+
+// renderNothing renders nodes that only exist for HTML output, like footnote backlinks.
+func (r *Renderer) renderNothing(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	return ast.WalkSkipChildren, nil
+}
+
+func (r *Renderer) renderFootnoteLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n, _ := node.(*extension_ast.FootnoteLink)
+
+	_, _ = w.WriteString("[^")
+	_, _ = w.Write(footnoteLabel(n))
+	_ = w.WriteByte(']')
+	return ast.WalkContinue, nil
+}
+
+// footnoteLabel finds the label of the footnote a link points to, since the link only has the footnote's index.
+func footnoteLabel(link *extension_ast.FootnoteLink) []byte {
+	var doc ast.Node = link
+	for doc.Parent() != nil {
+		doc = doc.Parent()
+	}
+
+	for child := doc.LastChild(); child != nil; child = child.PreviousSibling() {
+		list, ok := child.(*extension_ast.FootnoteList)
+		if !ok {
+			continue
+		}
+		for item := list.FirstChild(); item != nil; item = item.NextSibling() {
+			if footnote, ok := item.(*extension_ast.Footnote); ok && footnote.Index == link.Index {
+				return footnote.Ref
+			}
+		}
+	}
+	return []byte(strconv.Itoa(link.Index))
+}
+
+func (r *Renderer) renderFootnoteList(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	// Lossless documents keep the footnotes where they were in the original.
+	if r.Lossless {
+		return ast.WalkSkipChildren, nil
+	}
+	return ast.WalkContinue, nil
+}
+
+func (r *Renderer) renderFootnote(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n, _ := node.(*extension_ast.Footnote)
+
+	_, _ = w.WriteString("[^")
+	_, _ = w.Write(n.Ref)
+	_, _ = w.WriteString("]: ")
+	if err := r.renderIndentedChildren(w, source, n, "    "); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// renderIndentedChildren writes the blocks inside a node after a marker like [^1]: or :,
+// indenting the lines after the first so they stay inside the node.
+func (r *Renderer) renderIndentedChildren(w util.BufWriter, source []byte, n ast.Node, indent string) error {
+	contents, err := r.renderChildrenToString(source, n)
+	if err != nil {
+		return err
+	}
+
+	for i, line := range strings.Split(strings.TrimRight(contents, "\n"), "\n") {
+		if i > 0 {
+			_ = w.WriteByte('\n')
+			if line != "" {
+				_, _ = w.WriteString(indent)
+			}
+		}
+		_, _ = w.WriteString(line)
+	}
+	_ = w.WriteByte('\n')
+	return nil
+}
+
+func (r *Renderer) renderDefinitionList(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering && node.NextSibling() != nil {
+		_ = w.WriteByte('\n')
+	}
+	return ast.WalkContinue, nil
+}
+
+func (r *Renderer) renderDefinitionTerm(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if entering {
+		// Separate each term and its descriptions from the ones before it.
+		if node.PreviousSibling() != nil && node.PreviousSibling().Kind() == extension_ast.KindDefinitionDescription {
+			_ = w.WriteByte('\n')
+		}
+	} else {
+		_ = w.WriteByte('\n')
+	}
+	return ast.WalkContinue, nil
+}
+
+func (r *Renderer) renderDefinitionDescription(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n, _ := node.(*extension_ast.DefinitionDescription)
+
+	// Loose descriptions are separated from the term by a blank line.
+	if !n.IsTight {
+		_ = w.WriteByte('\n')
+	}
+	_, _ = w.WriteString(":   ")
+	if err := r.renderIndentedChildren(w, source, n, "    "); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkSkipChildren, nil
+}
+
+// renderFrontmatter renders the configured frontmatter, or the original frontmatter.
 func (r *Renderer) renderFrontmatter(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
 	if !entering {
 		return ast.WalkContinue, nil
 	}
 
+	n, _ := node.(*mdfront.Node)
+
 	if r.Config.Frontmatter == nil {
+		if r.Lossless {
+			// Frontmatter that was patched in place, like by larkdown.Marshal, is written from its new Raw.
+			var modified []byte
+			if gmast.IsModified(n) && n.HasFrontmatter() {
+				modified = n.Format.Fence(n.Delim, n.Raw)
+			}
+			r.renderLosslessFrontmatter(w, source, n, modified)
+			return ast.WalkContinue, nil
+		}
+
+		// Without an override, re-emit any frontmatter from the original document unchanged.
+		if n.HasFrontmatter() {
+			_, _ = w.Write(n.Format.Fence(n.Delim, n.Raw))
+			_ = w.WriteByte('\n')
+		}
 		return ast.WalkContinue, nil
 	}
 
-	data, err := yaml.Marshal(r.Config.Frontmatter)
+	// Keep the original format and delimiter, unless another format is configured.
+	format := mdfront.YAML
+	var delim []byte
+	if n.HasFrontmatter() {
+		format = n.Format
+		delim = n.Delim
+	}
+	if r.Config.FrontmatterFormat != nil && r.Config.FrontmatterFormat.Name != format.Name {
+		format = *r.Config.FrontmatterFormat
+		delim = nil
+	}
+
+	data, err := r.marshalFrontmatter(n, format)
 	if err != nil {
+		return ast.WalkStop, &FrontmatterError{Format: format.Name, Err: err}
+	}
+
+	if r.Lossless {
+		r.renderLosslessFrontmatter(w, source, n, format.Fence(delim, data))
 		return ast.WalkContinue, nil
 	}
 
 	// Print the frontmatter
-	_, _ = w.WriteString("---\n")
-	_, _ = w.Write(data)
-	_, _ = w.WriteString("---\n\n")
+	_, _ = w.Write(format.Fence(delim, data))
+	_ = w.WriteByte('\n')
 
 	return ast.WalkContinue, nil
 }
 
+// marshalFrontmatter marshals the configured frontmatter, patching the original frontmatter if configured.
+func (r *Renderer) marshalFrontmatter(n *mdfront.Node, format mdfront.Format) ([]byte, error) {
+	if !r.Config.FrontmatterPatch || !n.HasFrontmatter() {
+		return format.Marshal(r.Config.Frontmatter)
+	}
+
+	patched, err := n.Patch(r.Config.Frontmatter)
+	if err != nil {
+		return nil, err
+	}
+	if format.Name == n.Format.Name {
+		return patched, nil
+	}
+
+	// Convert the patched frontmatter to the configured format.
+	data := map[string]any{}
+	if err := n.Format.Unmarshal(patched, &data); err != nil {
+		return nil, err
+	}
+	return format.Marshal(data)
+}
+
 // A Writer interface writes textual contents to a writer.
 type Writer interface {
 	// Write writes the given source to writer with resolving references and unescaping
@@ -2,24 +2,35 @@ package mdrender_test
 
 import (
 	"bytes"
+	"errors"
+	"math"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/lithammer/dedent"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extension_ast "github.com/yuin/goldmark/extension/ast"
 	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 	"go.abhg.dev/goldmark/frontmatter"
 	"go.abhg.dev/goldmark/hashtag"
 
 	"github.com/will-wow/larkdown"
 	"github.com/will-wow/larkdown/gmast"
 	"github.com/will-wow/larkdown/match"
+	"github.com/will-wow/larkdown/mdcallout"
+	"github.com/will-wow/larkdown/mdfield"
 	"github.com/will-wow/larkdown/mdfront"
 	"github.com/will-wow/larkdown/mdrender"
+	"github.com/will-wow/larkdown/mdwiki"
 	"github.com/will-wow/larkdown/query"
 )
 
@@ -149,6 +160,358 @@ func TestFrontMatter(t *testing.T) {
 	require.Equal(t, string(source), rendered.String())
 }
 
+func TestParsedFrontMatter(t *testing.T) {
+	md := goldmark.New(
+		goldmark.WithExtensions(&mdfront.Extender{}),
+		goldmark.WithRenderer(larkdown.NewNodeRenderer()),
+	)
+
+	sources := map[string]string{
+		"yaml": "---\ntitle: My Recipe\n# A comment\ntags: [dinner]\n---\n\n# Title\n\n",
+		"toml": "+++\ntitle = \"My Recipe\"\n+++\n\n# Title\n\n",
+		"json": "{\n  \"title\": \"My Recipe\"\n}\n\n# Title\n\n",
+	}
+
+	for name, source := range sources {
+		t.Run(name, func(t *testing.T) {
+			doc := md.Parser().Parse(text.NewReader([]byte(source)))
+
+			var rendered bytes.Buffer
+			err := md.Renderer().Render(&rendered, []byte(source), doc)
+			require.NoError(t, err)
+			require.Equal(t, source, rendered.String())
+		})
+	}
+}
+
+func TestUnclosedFrontMatter(t *testing.T) {
+	md := goldmark.New(
+		goldmark.WithExtensions(&mdfront.Extender{}),
+		goldmark.WithRenderer(larkdown.NewNodeRenderer()),
+	)
+
+	source := []byte("---\n\n# Title\n\nBody\n")
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	var rendered bytes.Buffer
+	err := md.Renderer().Render(&rendered, source, doc)
+	require.NoError(t, err)
+	require.Equal(t, string(source), rendered.String())
+}
+
+func TestFrontMatterFormats(t *testing.T) {
+	type metaData struct {
+		Title string `yaml:"title" toml:"title" json:"title"`
+	}
+	data := metaData{Title: "New Title"}
+
+	render := func(t *testing.T, source string, opts ...mdrender.Option) string {
+		md := goldmark.New(goldmark.WithExtensions(&mdfront.Extender{}))
+		doc := md.Parser().Parse(text.NewReader([]byte(source)))
+
+		var rendered bytes.Buffer
+		opts = append([]mdrender.Option{mdrender.WithFrontmatter(data)}, opts...)
+		err := larkdown.NewNodeRenderer(opts...).Render(&rendered, []byte(source), doc)
+		require.NoError(t, err)
+		return rendered.String()
+	}
+
+	t.Run("keeps the original format and delimiter", func(t *testing.T) {
+		rendered := render(t, "++++\ntitle = \"Old Title\"\n++++\n\n# Title\n")
+		require.Equal(t, "++++\ntitle = \"New Title\"\n++++\n\n# Title\n\n", rendered)
+
+		rendered = render(t, "{\n  \"title\": \"Old Title\"\n}\n\n# Title\n")
+		require.Equal(t, "{\n  \"title\": \"New Title\"\n}\n\n# Title\n\n", rendered)
+	})
+
+	t.Run("defaults to yaml", func(t *testing.T) {
+		rendered := render(t, "# Title\n")
+		require.Equal(t, "---\ntitle: New Title\n---\n\n# Title\n\n", rendered)
+	})
+
+	t.Run("configured format", func(t *testing.T) {
+		rendered := render(t, "---\ntitle: Old Title\n---\n\n# Title\n", mdrender.WithFrontmatterFormat(mdfront.TOML))
+		require.Equal(t, "+++\ntitle = \"New Title\"\n+++\n\n# Title\n\n", rendered)
+
+		rendered = render(t, "# Title\n", mdrender.WithFrontmatterFormat(mdfront.JSON))
+		require.Equal(t, "{\n  \"title\": \"New Title\"\n}\n\n# Title\n\n", rendered)
+	})
+}
+
+func TestFrontMatterPatch(t *testing.T) {
+	type metaData struct {
+		Slug string `yaml:"slug"`
+	}
+
+	render := func(t *testing.T, source string) string {
+		md := goldmark.New(goldmark.WithExtensions(&mdfront.Extender{}))
+		doc := md.Parser().Parse(text.NewReader([]byte(source)))
+
+		var rendered bytes.Buffer
+		err := larkdown.NewNodeRenderer(
+			mdrender.WithFrontmatterPatch(metaData{Slug: "my-recipe"}),
+		).Render(&rendered, []byte(source), doc)
+		require.NoError(t, err)
+		return rendered.String()
+	}
+
+	t.Run("patches the original frontmatter", func(t *testing.T) {
+		rendered := render(t, "---\ntitle: My Recipe # editor managed\n---\n\n# Title\n")
+		require.Equal(t, "---\ntitle: My Recipe # editor managed\nslug: my-recipe\n---\n\n# Title\n\n", rendered)
+	})
+
+	t.Run("adds frontmatter to a document without it", func(t *testing.T) {
+		rendered := render(t, "# Title\n")
+		require.Equal(t, "---\nslug: my-recipe\n---\n\n# Title\n\n", rendered)
+	})
+}
+
+func TestFrontMatterError(t *testing.T) {
+	source := []byte("# Title\n")
+	md := goldmark.New(goldmark.WithExtensions(&mdfront.Extender{}))
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	var rendered bytes.Buffer
+	err := larkdown.NewNodeRenderer(
+		mdrender.WithFrontmatter(map[string]any{"callback": func() {}}),
+	).Render(&rendered, source, doc)
+
+	var frontmatterErr *mdrender.FrontmatterError
+	require.True(t, errors.As(err, &frontmatterErr), "error is a FrontmatterError")
+	require.Equal(t, "YAML", frontmatterErr.Format)
+}
+
+// unsupportedNode is a node kind that the renderer doesn't know about.
+type unsupportedNode struct {
+	ast.BaseInline
+}
+
+var kindUnsupported = ast.NewNodeKind("Unsupported")
+
+func (n *unsupportedNode) Kind() ast.NodeKind { return kindUnsupported }
+
+func (n *unsupportedNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+func TestStrict(t *testing.T) {
+	source := []byte("# Title\n\nSome text\n")
+
+	t.Run("fails on unsupported nodes in strict mode", func(t *testing.T) {
+		doc := goldmark.New().Parser().Parse(text.NewReader(source))
+		paragraph := doc.LastChild()
+		paragraph.AppendChild(paragraph, &unsupportedNode{})
+
+		var rendered bytes.Buffer
+		err := larkdown.NewNodeRenderer(mdrender.WithStrict()).Render(&rendered, source, doc)
+
+		var unsupportedErr *mdrender.UnsupportedNodeError
+		require.True(t, errors.As(err, &unsupportedErr), "error is an UnsupportedNodeError")
+		require.Equal(t, kindUnsupported, unsupportedErr.Kind)
+		require.Empty(t, rendered.String(), "nothing is rendered")
+	})
+
+	t.Run("renders supported documents in strict mode", func(t *testing.T) {
+		doc := goldmark.New().Parser().Parse(text.NewReader(source))
+
+		var rendered bytes.Buffer
+		err := larkdown.NewNodeRenderer(mdrender.WithStrict()).Render(&rendered, source, doc)
+		require.NoError(t, err)
+		require.Equal(t, "# Title\n\nSome text\n", rendered.String())
+	})
+
+	t.Run("checks subtrees in strict mode", func(t *testing.T) {
+		doc := goldmark.New().Parser().Parse(text.NewReader(source))
+		paragraph := doc.LastChild()
+		paragraph.AppendChild(paragraph, &unsupportedNode{})
+
+		var rendered bytes.Buffer
+		err := larkdown.NewNodeRenderer(mdrender.WithStrict()).Render(&rendered, source, paragraph)
+
+		var unsupportedErr *mdrender.UnsupportedNodeError
+		require.True(t, errors.As(err, &unsupportedErr), "error is an UnsupportedNodeError")
+		require.Empty(t, rendered.String(), "nothing is rendered")
+	})
+
+	t.Run("allows nodes that other renderers support", func(t *testing.T) {
+		doc := goldmark.New().Parser().Parse(text.NewReader(source))
+		paragraph := doc.LastChild()
+		paragraph.AppendChild(paragraph, &unsupportedNode{})
+
+		r := renderer.NewRenderer(renderer.WithNodeRenderers(
+			util.Prioritized(mdrender.NewRenderer(mdrender.WithStrict()), 100),
+			util.Prioritized(&unsupportedNodeRenderer{}, 500),
+			util.Prioritized(&mdrender.UnsupportedRenderer{}, math.MaxInt32),
+		))
+
+		var rendered bytes.Buffer
+		err := r.Render(&rendered, source, doc)
+		require.NoError(t, err)
+		require.Equal(t, "# Title\n\nSome text(custom)\n", rendered.String())
+	})
+
+	t.Run("shares a renderer between goroutines", func(t *testing.T) {
+		r := larkdown.NewNodeRenderer(mdrender.WithStrict())
+
+		var wg sync.WaitGroup
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				doc := goldmark.New(goldmark.WithExtensions(extension.Table)).Parser().Parse(
+					text.NewReader([]byte("| a |\n| - |\n| 1 |\n")))
+
+				var rendered bytes.Buffer
+				assert.NoError(t, r.Render(&rendered, source, doc))
+			}()
+		}
+		wg.Wait()
+	})
+}
+
+// unsupportedNodeRenderer is another renderer that supports unsupportedNode.
+type unsupportedNodeRenderer struct{}
+
+func (r *unsupportedNodeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(kindUnsupported, func(w util.BufWriter, _ []byte, _ ast.Node, entering bool) (ast.WalkStatus, error) {
+		if entering {
+			_, _ = w.WriteString("(custom)")
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// messyMarkdown uses syntax that the renderer normalizes, to check that lossless rendering keeps it.
+const messyMarkdown = "---\ntitle: Messy # comment\n---\n\nMy Title\n========\n\n" +
+	"Some *emphasis*, __strong__, ``code ` span``, and <https://example.com>.\n" +
+	"A [ref link][ref] here.\n\n[ref]: https://example.com \"Title\"\n\n" +
+	"1. one\n2. two\n\n" +
+	"* [ ] task one\n* [x] task two\n\n" +
+	"~~~go\nfunc main() {}\n~~~\n\n" +
+	"***\n\n" +
+	"> quoted *text*\n\n" +
+	"| a | b |\n|---|:-:|\n| 1 | 2 |\n\n" +
+	"- outer\n    - inner *one*\n- last\n\n" +
+	"Final #tag line\n"
+
+func TestLossless(t *testing.T) {
+	parse := func(source []byte) ast.Node {
+		md := goldmark.New(goldmark.WithExtensions(
+			extension.GFM,
+			&mdfront.Extender{},
+			&hashtag.Extender{Variant: hashtag.ObsidianVariant},
+		))
+		return md.Parser().Parse(text.NewReader(source))
+	}
+
+	t.Run("copies an unmodified document", func(t *testing.T) {
+		source := []byte(messyMarkdown)
+		doc := parse(source)
+
+		var rendered bytes.Buffer
+		err := larkdown.NewNodeRenderer(mdrender.WithLossless(source)).Render(&rendered, source, doc)
+		require.NoError(t, err)
+		require.Equal(t, messyMarkdown, rendered.String())
+	})
+
+	t.Run("only renders modified blocks", func(t *testing.T) {
+		original := []byte(messyMarkdown)
+		doc := parse(original)
+		source := original
+
+		tasks, err := query.QueryOne(doc, source, []match.Node{match.TaskList{}})
+		require.NoError(t, err)
+		_, err = gmast.ToggleTask(tasks.FirstChild())
+		require.NoError(t, err)
+
+		lists, err := query.QueryAll(doc, source, []match.Node{}, match.List{})
+		require.NoError(t, err)
+		nested, _ := lists[2].FirstChild().LastChild().(*ast.List)
+		item, source := gmast.NewListItem("inner two", nested, source)
+		gmast.AppendChild(nested, item)
+
+		last := doc.LastChild()
+		space, source := gmast.NewSpace(source)
+		tag, source := gmast.NewHashtag("new", source)
+		gmast.AppendChild(last, space, tag)
+
+		var rendered bytes.Buffer
+		err = larkdown.NewNodeRenderer(
+			mdrender.WithLossless(original),
+			mdrender.WithFrontmatterPatch(map[string]string{"slug": "messy"}),
+		).Render(&rendered, source, doc)
+		require.NoError(t, err)
+
+		expected := strings.NewReplacer(
+			"title: Messy # comment\n", "title: Messy # comment\nslug: messy\n",
+			"* [ ] task one", "* [x] task one",
+			"    - inner *one*\n", "    - inner *one*\n    - inner two\n",
+			"Final #tag line\n", "Final #tag line #new\n",
+		).Replace(messyMarkdown)
+		require.Equal(t, expected, rendered.String())
+	})
+
+	t.Run("replaces frontmatter and keeps the text after it", func(t *testing.T) {
+		source := []byte("---\ntitle: Old\n---\n# Title\n")
+		doc := parse(source)
+
+		var rendered bytes.Buffer
+		err := larkdown.NewNodeRenderer(
+			mdrender.WithLossless(source),
+			mdrender.WithFrontmatter(map[string]string{"title": "New"}),
+		).Render(&rendered, source, doc)
+		require.NoError(t, err)
+		require.Equal(t, "---\ntitle: New\n---\n# Title\n", rendered.String())
+	})
+
+	t.Run("copies blocks without text after a modified block", func(t *testing.T) {
+		original := []byte("Some text\n\n***\n\nMore text\n\n* * *\n")
+		doc := parse(original)
+		source := original
+
+		paragraph, err := query.QueryOne(doc, source, []match.Node{match.NodeOfKind{Kind: ast.KindParagraph}})
+		require.NoError(t, err)
+		text, source := gmast.NewTextSegment("New text", source)
+		gmast.ReplaceChildren(paragraph, text)
+
+		var rendered bytes.Buffer
+		err = larkdown.NewNodeRenderer(mdrender.WithLossless(original)).Render(&rendered, source, doc)
+		require.NoError(t, err)
+		require.Equal(t, "New text\n\n***\n\nMore text\n\n* * *\n", rendered.String())
+	})
+
+	t.Run("writes new list items like the other items", func(t *testing.T) {
+		cases := map[string]string{
+			"1) one\n2) two\n":          "1) one\n2) two\n3) new\n",
+			"1. one\n1. two\n":          "1. one\n1. two\n1. new\n",
+			"3. three\n":                "3. three\n4. new\n",
+			"* one\n* two\n":            "* one\n* two\n* new\n",
+			"- one\n\n- two\n":          "- one\n\n- two\n\n- new\n",
+			"1. one\n\n2. two\n\nEnd\n": "1. one\n\n2. two\n\n3. new\n\nEnd\n",
+		}
+
+		for markdown, expected := range cases {
+			original := []byte(markdown)
+			doc := parse(original)
+			source := original
+
+			node, err := query.QueryOne(doc, source, []match.Node{match.List{}})
+			require.NoError(t, err)
+			list, _ := node.(*ast.List)
+			item, source := gmast.NewListItem("new", list, source)
+			gmast.AppendChild(list, item)
+
+			var rendered bytes.Buffer
+			err = larkdown.NewNodeRenderer(
+				mdrender.WithLossless(original),
+				mdrender.WithBulletMarker('-'),
+			).Render(&rendered, source, doc)
+			require.NoError(t, err)
+			require.Equal(t, expected, rendered.String(), markdown)
+		}
+	})
+}
+
 func setup(t *testing.T) (source []byte, md goldmark.Markdown, doc ast.Node) {
 	source, err := os.ReadFile("../examples/all-tags.md")
 	require.NoError(t, err, "error reading markdown file")
@@ -164,3 +527,539 @@ func setup(t *testing.T) (source []byte, md goldmark.Markdown, doc ast.Node) {
 
 	return source, md, doc
 }
+
+func TestStyle(t *testing.T) {
+	render := func(t *testing.T, source string, opts ...mdrender.Option) string {
+		t.Helper()
+		md := goldmark.New(goldmark.WithRenderer(larkdown.NewNodeRenderer(opts...)))
+		doc := md.Parser().Parse(text.NewReader([]byte(source)))
+
+		var rendered bytes.Buffer
+		err := md.Renderer().Render(&rendered, []byte(source), doc)
+		require.NoError(t, err)
+		return rendered.String()
+	}
+
+	t.Run("uses the default style", func(t *testing.T) {
+		source := "# Title\n\n- _a_ and **b**\n\n1. one\n1. two\n"
+		require.Equal(t, source, render(t, source))
+	})
+
+	t.Run("configures markers", func(t *testing.T) {
+		rendered := render(t,
+			"- _a_ and **b**\n\n3. one\n4. two\n",
+			mdrender.WithBulletMarker('*'),
+			mdrender.WithEmphasisMarker('*'),
+			mdrender.WithStrongMarker('_'),
+			mdrender.WithOrderedNumbering(mdrender.NumberingIncrement),
+		)
+		require.Equal(t, "* *a* and __b__\n\n3. one\n4. two\n", rendered)
+	})
+
+	t.Run("writes setext headings", func(t *testing.T) {
+		rendered := render(t, "# Title\n\n## Hi\n\n### Sub\n", mdrender.WithHeadingStyle(mdrender.HeadingSetext))
+		require.Equal(t, "Title\n=====\n\nHi\n---\n\n### Sub\n\n", rendered)
+	})
+
+	t.Run("writes fences longer than the code's fences", func(t *testing.T) {
+		rendered := render(t, "````md\n~~~\ncode\n~~~\n````\n", mdrender.WithFenceChar('~'))
+		require.Equal(t, "~~~~md\n~~~\ncode\n~~~\n~~~~\n\n", rendered)
+	})
+
+	t.Run("wraps paragraphs", func(t *testing.T) {
+		rendered := render(t,
+			"one two three `four five` six\nthirteen-char - seven\n\n- nine ten eleven twelve\n",
+			mdrender.WithStyle(mdrender.Style{WrapWidth: 14}),
+		)
+		require.Equal(t, strings.Join([]string{
+			"one two three",
+			"`four five`",
+			"six",
+			"thirteen-char -",
+			"seven",
+			"",
+			"- nine ten",
+			"  eleven",
+			"  twelve",
+			"",
+		}, "\n"), rendered)
+	})
+}
+
+func TestEmphasisAndCodeSpans(t *testing.T) {
+	tests := map[string]struct {
+		source   string
+		opts     []mdrender.Option
+		expected string
+	}{
+		"strong inside emphasis": {
+			source:   "***both***\n",
+			expected: "_**both**_\n",
+		},
+		"emphasis inside strong with matching markers": {
+			source:   "**_both_**\n",
+			opts:     []mdrender.Option{mdrender.WithEmphasisMarker('*')},
+			expected: "**_both_**\n",
+		},
+		"emphasis inside emphasis": {
+			source:   "*a _b_ c*\n",
+			expected: "_a *b* c_\n",
+		},
+		"emphasis inside a word": {
+			source:   "foo*bar*baz **qux**quux\n",
+			expected: "foo*bar*baz **qux**quux\n",
+		},
+		"code with a backtick": {
+			source:   "``a`b``\n",
+			expected: "``a`b``\n",
+		},
+		"code with double backticks": {
+			source:   "` `` `\n",
+			expected: "` `` `\n",
+		},
+		"code starting with a backtick": {
+			source:   "`` `a ``\n",
+			expected: "`` `a ``\n",
+		},
+		"code with significant spaces": {
+			source:   "`  a  `\n",
+			expected: "`  a  `\n",
+		},
+		"code of only spaces": {
+			source:   "`  `\n",
+			expected: "`  `\n",
+		},
+	}
+
+	for name, tt := range tests {
+		tt := tt
+		t.Run(name, func(t *testing.T) {
+			md := goldmark.New(goldmark.WithRenderer(larkdown.NewNodeRenderer(tt.opts...)))
+			source := []byte(tt.source)
+			doc := md.Parser().Parse(text.NewReader(source))
+
+			var rendered bytes.Buffer
+			err := md.Renderer().Render(&rendered, source, doc)
+			require.NoError(t, err)
+			require.Equal(t, tt.expected, rendered.String())
+
+			reparsed := md.Parser().Parse(text.NewReader(rendered.Bytes()))
+			require.Equal(t, inlineTree(doc, source), inlineTree(reparsed, rendered.Bytes()), "re-parses to the same AST")
+		})
+	}
+}
+
+// inlineTree summarizes the kinds and text of a document's nodes, for comparing documents parsed from different sources.
+func inlineTree(doc ast.Node, source []byte) string {
+	var tree strings.Builder
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			tree.WriteString(")")
+			return ast.WalkContinue, nil
+		}
+
+		tree.WriteString("(" + n.Kind().String())
+		switch n := n.(type) {
+		case *ast.Emphasis:
+			tree.WriteString(strings.Repeat("!", n.Level))
+		case *ast.Text:
+			tree.WriteString(" " + string(n.Segment.Value(source)))
+		}
+		return ast.WalkContinue, nil
+	})
+	return tree.String()
+}
+
+func TestReferenceLinks(t *testing.T) {
+	source := []byte(strings.TrimLeft(dedent.Dedent(`
+	# Links
+
+	A [shortcut] link, a [full][ref] link, a [collapsed][] link,
+	and an [inline](/inline 'with "quotes"') link.
+
+	![image][ref]
+
+	[shortcut]: /shortcut
+	[ref]: </with space> "Title"
+	[collapsed]: /collapsed (parens)
+	`), "\n"))
+
+	t.Run("keeps reference links and definitions", func(t *testing.T) {
+		pc := parser.NewContext()
+		md := goldmark.New(goldmark.WithRenderer(larkdown.NewNodeRenderer(mdrender.WithParserContext(pc))))
+		doc := md.Parser().Parse(text.NewReader(source), parser.WithContext(pc))
+
+		var rendered bytes.Buffer
+		err := md.Renderer().Render(&rendered, source, doc)
+		require.NoError(t, err)
+
+		require.Equal(t, strings.Join([]string{
+			"# Links",
+			"",
+			"A [shortcut] link, a [full][ref] link, a [collapsed][] link,",
+			`and an [inline](/inline "with \"quotes\"") link.`,
+			"",
+			"![image][ref]",
+			"",
+			"[shortcut]: /shortcut",
+			`[ref]: </with space> "Title"`,
+			`[collapsed]: /collapsed "parens"`,
+			"",
+		}, "\n"), rendered.String())
+	})
+
+	t.Run("keeps inline links that match a definition inline", func(t *testing.T) {
+		source := []byte("See [docs](/u), [_em_][foo], [`code`][] and [foo], ![image](/u) and [edited].\n\n[foo]: /u\n[edited]: /old\n[`code`]: /u\n")
+		pc := parser.NewContext()
+		md := goldmark.New(goldmark.WithRenderer(larkdown.NewNodeRenderer(mdrender.WithParserContext(pc))))
+		doc := md.Parser().Parse(text.NewReader(source), parser.WithContext(pc))
+
+		edited, _ := doc.FirstChild().LastChild().PreviousSibling().(*ast.Link)
+		require.NotNil(t, edited)
+		edited.Destination = []byte("/new")
+
+		var rendered bytes.Buffer
+		err := md.Renderer().Render(&rendered, source, doc)
+		require.NoError(t, err)
+
+		require.Equal(t, "See [docs](/u), [_em_][foo], [`code`][] and [foo], ![image](/u) and [edited](/new).\n\n[foo]: /u\n[edited]: /old\n[`code`]: /u\n", rendered.String())
+	})
+
+	t.Run("writes inline links without a parser context", func(t *testing.T) {
+		md := goldmark.New(goldmark.WithRenderer(larkdown.NewNodeRenderer()))
+		doc := md.Parser().Parse(text.NewReader(source))
+
+		var rendered bytes.Buffer
+		err := md.Renderer().Render(&rendered, source, doc)
+		require.NoError(t, err)
+
+		require.Contains(t, rendered.String(), `a [full](</with space> "Title") link`)
+		require.NotContains(t, rendered.String(), "[shortcut]:")
+	})
+}
+
+func TestTableRenderer(t *testing.T) {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.Table),
+		goldmark.WithRenderer(larkdown.NewNodeRenderer()),
+	)
+
+	t.Run("round-trips a formatted table", func(t *testing.T) {
+		source := []byte(strings.TrimLeft(dedent.Dedent(`
+		# Table
+
+		| Left | Right | Center | None       |
+		| :--- | ----: | :----: | ---------- |
+		| a    |     1 |   x    | **strong** |
+		| \| b |    22 |   yy   | `+"`c\\|d`"+`     |
+
+		After
+		`), "\n"))
+
+		doc := md.Parser().Parse(text.NewReader(source))
+
+		var rendered bytes.Buffer
+		err := md.Renderer().Render(&rendered, source, doc)
+		require.NoError(t, err)
+
+		require.Equal(t, string(source), rendered.String())
+	})
+
+	t.Run("pads columns and escapes pipes", func(t *testing.T) {
+		source := []byte("|a|b|\n|-|:-:|\n|long cell|`x\\|y`|\n")
+		doc := md.Parser().Parse(text.NewReader(source))
+
+		var rendered bytes.Buffer
+		err := md.Renderer().Render(&rendered, source, doc)
+		require.NoError(t, err)
+
+		require.Equal(t, strings.Join([]string{
+			"| a         |   b    |",
+			"| --------- | :----: |",
+			"| long cell | `x\\|y` |",
+			"",
+		}, "\n"), rendered.String())
+	})
+
+	t.Run("indents tables in list items", func(t *testing.T) {
+		for _, markdown := range []string{
+			"- a\n  | x   |\n  | --- |\n  | 1   |\n- c\n",
+			"1. a\n   - b\n     | x   |\n     | --- |\n     | 1   |\n",
+			"- a\n\n  | x   |\n  | --- |\n  | 1   |\n\n  After\n",
+		} {
+			source := []byte(markdown)
+			doc := md.Parser().Parse(text.NewReader(source))
+
+			var rendered bytes.Buffer
+			err := md.Renderer().Render(&rendered, source, doc)
+			require.NoError(t, err)
+			require.Equal(t, markdown, rendered.String())
+
+			// The table is still in a list item when the output is parsed again.
+			reparsed := md.Parser().Parse(text.NewReader(rendered.Bytes()))
+			var table ast.Node
+			_ = ast.Walk(reparsed, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+				if entering && n.Kind() == extension_ast.KindTable {
+					table = n
+				}
+				return ast.WalkContinue, nil
+			})
+			require.NotNil(t, table, markdown)
+			require.Equal(t, ast.KindListItem, table.Parent().Kind(), markdown)
+		}
+	})
+}
+
+func TestGFMRenderer(t *testing.T) {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.GFM),
+		goldmark.WithRenderer(larkdown.NewNodeRenderer()),
+	)
+
+	source := []byte(strings.TrimLeft(dedent.Dedent(`
+	# Todo
+
+	- [ ] write ~~tests~~ specs
+	- [x] done
+	  - [ ] nested
+
+	1. [ ] loose
+
+	   with a paragraph
+
+	1. [x] second
+
+	Links like www.example.com, https://example.com and me@example.com stay bare.
+
+	Bracketed links <https://example.com> and <me@example.com> keep their brackets.
+	`), "\n"))
+
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	var rendered bytes.Buffer
+	err := md.Renderer().Render(&rendered, source, doc)
+	require.NoError(t, err)
+
+	// Print the ast if the test is going to fail
+	if string(source) != rendered.String() {
+		doc.Dump(source, 3)
+	}
+
+	require.Equal(t, string(source), rendered.String())
+}
+
+func TestFootnotesAndDefinitionLists(t *testing.T) {
+	md := goldmark.New(
+		goldmark.WithExtensions(extension.Footnote, extension.DefinitionList),
+		goldmark.WithRenderer(larkdown.NewNodeRenderer()),
+	)
+
+	source := []byte(strings.TrimLeft(dedent.Dedent(`
+	# Glossary
+
+	Apple
+	:   A fruit.
+
+	    Grows on trees.
+
+	Orange
+	Tangerine
+	:   Citrus[^citrus].
+	:   Colors.
+
+	Some text[^1].
+
+	[^citrus]: Not apples.
+	[^1]: A note
+
+	    with a second paragraph.
+	`), "\n"))
+
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	var rendered bytes.Buffer
+	err := md.Renderer().Render(&rendered, source, doc)
+	require.NoError(t, err)
+
+	// Print the ast if the test is going to fail
+	if string(source) != rendered.String() {
+		doc.Dump(source, 3)
+	}
+
+	require.Equal(t, string(source), rendered.String())
+}
+
+func TestWikiLinks(t *testing.T) {
+	md := goldmark.New(
+		goldmark.WithExtensions(&mdwiki.Extender{}),
+		goldmark.WithRenderer(larkdown.NewNodeRenderer()),
+	)
+
+	source := []byte(strings.TrimLeft(dedent.Dedent(`
+	# Reading
+
+	- [[Dune]] by [[Frank Herbert|Herbert]]
+	- [[Dune#Characters|Paul]] and [[#Reading]]
+
+	![[dune.png]]
+	`), "\n"))
+
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	t.Run("keeps wikilinks intact", func(t *testing.T) {
+		var rendered bytes.Buffer
+		err := md.Renderer().Render(&rendered, source, doc)
+		require.NoError(t, err)
+
+		require.Equal(t, string(source), rendered.String())
+	})
+
+	t.Run("renders edited wikilinks", func(t *testing.T) {
+		_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+			if link, ok := n.(*mdwiki.Node); ok && entering && string(link.Target) == "Dune" {
+				link.Target = []byte("Dune (novel)")
+			}
+			return ast.WalkContinue, nil
+		})
+
+		var rendered bytes.Buffer
+		err := md.Renderer().Render(&rendered, source, doc)
+		require.NoError(t, err)
+
+		require.Contains(t, rendered.String(), "- [[Dune (novel)]] by [[Frank Herbert|Herbert]]\n")
+		require.Contains(t, rendered.String(), "- [[Dune (novel)#Characters|Paul]] and [[#Reading]]\n")
+	})
+}
+
+func TestBlockquotesAndCallouts(t *testing.T) {
+	md := goldmark.New(
+		goldmark.WithExtensions(&mdcallout.Extender{}),
+		goldmark.WithRenderer(larkdown.NewNodeRenderer()),
+	)
+
+	source := []byte(strings.TrimLeft(dedent.Dedent(`
+	# Notes
+
+	> A quote with a list:
+	>
+	> - one
+	>   - nested
+	> - two
+	>
+	> `+"```go"+`
+	> fmt.Println("hi")
+	> `+"```"+`
+	>
+	> > nested quote
+
+	- item
+	  > quoted in a list
+	  > over two lines
+	- next
+
+	> [!warning]- Hot pan
+	> Use oven mitts.
+	>
+	> - Take it out
+	> - Let it cool
+
+	> [!NOTE]
+	>
+	> - no title
+	`), "\n"))
+
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	t.Run("renders nested blocks in quotes", func(t *testing.T) {
+		var rendered bytes.Buffer
+		err := md.Renderer().Render(&rendered, source, doc)
+		require.NoError(t, err)
+
+		// Print the ast if the test is going to fail
+		if string(source) != rendered.String() {
+			doc.Dump(source, 3)
+		}
+
+		require.Equal(t, string(source), rendered.String())
+	})
+
+	t.Run("keeps the body of callouts after the header line", func(t *testing.T) {
+		for _, markdown := range []string{
+			"> [!note] Title\n> <http://x.com> after\n",
+			"> [!note] Title\n> <b>hi</b> after\n",
+			"> [!note] <b>Title</b>\n> after\n",
+			"> [!note] Title\n> [](/empty) after\n",
+		} {
+			source := []byte(markdown)
+			doc := md.Parser().Parse(text.NewReader(source))
+
+			var rendered bytes.Buffer
+			err := md.Renderer().Render(&rendered, source, doc)
+			require.NoError(t, err)
+			require.Equal(t, markdown, rendered.String())
+		}
+	})
+
+	t.Run("keeps the text after the header line in inline nodes that span it", func(t *testing.T) {
+		source := []byte("> [!note] *Title\n> more* after\n")
+		doc := md.Parser().Parse(text.NewReader(source))
+
+		var rendered bytes.Buffer
+		err := md.Renderer().Render(&rendered, source, doc)
+		require.NoError(t, err)
+		require.Equal(t, "> [!note] *Title\n> _more_ after\n", rendered.String())
+	})
+
+	t.Run("renders edited callouts", func(t *testing.T) {
+		callout, err := larkdown.Find(doc, source, []match.Node{match.Callout{Type: "warning"}},
+			func(node ast.Node, source []byte) (*mdcallout.Node, error) {
+				return node.(*mdcallout.Node), nil
+			})
+		require.NoError(t, err)
+
+		callout.CalloutType = []byte("danger")
+		callout.Fold = mdcallout.FoldNone
+		gmast.MarkModified(callout)
+
+		var rendered bytes.Buffer
+		err = larkdown.NewNodeRenderer(mdrender.WithLossless(source)).Render(&rendered, source, doc)
+		require.NoError(t, err)
+
+		require.Equal(t, strings.Replace(string(source), "[!warning]- Hot pan", "[!danger] Hot pan", 1), rendered.String())
+	})
+}
+
+func TestInlineFields(t *testing.T) {
+	md := goldmark.New(
+		goldmark.WithExtensions(&mdfield.Extender{}, &mdwiki.Extender{}),
+		goldmark.WithRenderer(larkdown.NewNodeRenderer()),
+	)
+
+	source := []byte(strings.TrimLeft(dedent.Dedent(`
+	# Dune
+
+	rating:: 5
+	author:: [[Frank Herbert]]
+	Read in [days:: 12] (mood:: happy).
+
+	- pages:: 412
+	- started::
+
+	tight::value
+	spaced::   out
+	text with std::vector here, and a::b in text.
+	`), "\n"))
+
+	doc := md.Parser().Parse(text.NewReader(source))
+
+	var rendered bytes.Buffer
+	err := md.Renderer().Render(&rendered, source, doc)
+	require.NoError(t, err)
+
+	// Print the ast if the test is going to fail
+	if string(source) != rendered.String() {
+		doc.Dump(source, 3)
+	}
+
+	require.Equal(t, string(source), rendered.String())
+}
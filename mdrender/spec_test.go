@@ -0,0 +1,350 @@
+package mdrender_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	extension_ast "github.com/yuin/goldmark/extension/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+
+	"github.com/will-wow/larkdown"
+	"github.com/will-wow/larkdown/mdrender"
+)
+
+var updateSpec = flag.Bool("update-spec", false, "update the list of spec examples that round-trip")
+
+// specBaseline lists the spec examples that are known to round-trip, so regressions fail the tests.
+const specBaseline = "testdata/spec/passing.txt"
+
+// specExample is an example from the CommonMark spec, or from goldmark's extension tests.
+type specExample struct {
+	// Name identifies the example in the baseline, like commonmark/12.
+	Name     string
+	Section  string
+	Markdown string
+	// Extensions are the goldmark extensions the example needs.
+	Extensions []goldmark.Extender
+}
+
+// TestSpecRoundTrip renders each CommonMark and GFM spec example back to markdown,
+// and checks that it parses to the same AST and HTML as the original.
+// The spec examples are copied from github.com/yuin/goldmark's tests.
+//
+// Examples that don't round-trip yet are reported in the per-section pass rates, and only fail the test
+// if they are in the baseline. Run with -update-spec to update the baseline after improving the renderer.
+func TestSpecRoundTrip(t *testing.T) {
+	examples := loadSpecExamples(t)
+
+	type sectionResult struct {
+		passed int
+		total  int
+	}
+	sections := map[string]*sectionResult{}
+	var sectionNames []string
+
+	baseline := loadSpecBaseline(t)
+	var passing []string
+
+	for _, example := range examples {
+		result, ok := sections[example.Section]
+		if !ok {
+			result = &sectionResult{}
+			sections[example.Section] = result
+			sectionNames = append(sectionNames, example.Section)
+		}
+		result.total++
+
+		err := roundTripSpecExample(example)
+		if err == nil {
+			result.passed++
+			passing = append(passing, example.Name)
+			continue
+		}
+
+		if baseline[example.Name] && !*updateSpec {
+			t.Errorf("%s (%s) no longer round-trips: %v\nmarkdown:\n%s", example.Name, example.Section, err, example.Markdown)
+		}
+	}
+
+	passed := 0
+	for _, name := range sectionNames {
+		result := sections[name]
+		passed += result.passed
+		t.Logf("%-40s %3d/%3d", name, result.passed, result.total)
+	}
+	t.Logf("%-40s %3d/%3d", "Total", passed, len(examples))
+
+	if *updateSpec {
+		err := os.WriteFile(specBaseline, []byte(strings.Join(passing, "\n")+"\n"), 0o644)
+		require.NoError(t, err)
+	}
+}
+
+// roundTripSpecExample renders an example to markdown, and compares the AST and HTML
+// of the original and the rendered markdown.
+func roundTripSpecExample(example specExample) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	extensions := example.Extensions
+	source := []byte(example.Markdown)
+	pc := parser.NewContext()
+	md := goldmark.New(
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithRenderer(larkdown.NewNodeRenderer(mdrender.WithParserContext(pc))),
+	)
+	htmlMd := goldmark.New(
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithRendererOptions(html.WithUnsafe()),
+	)
+
+	doc := md.Parser().Parse(text.NewReader(source), parser.WithContext(pc))
+
+	var rendered bytes.Buffer
+	if err := md.Renderer().Render(&rendered, source, doc); err != nil {
+		return fmt.Errorf("render: %w", err)
+	}
+
+	reparsed := md.Parser().Parse(text.NewReader(rendered.Bytes()))
+	expectedTree := specTree(md.Parser().Parse(text.NewReader(source)), source)
+	actualTree := specTree(reparsed, rendered.Bytes())
+	if expectedTree != actualTree {
+		return fmt.Errorf("AST differs\nrendered:\n%s\nexpected: %s\nactual:   %s", rendered.String(), expectedTree, actualTree)
+	}
+
+	var expectedHTML, actualHTML bytes.Buffer
+	if err := htmlMd.Convert(source, &expectedHTML); err != nil {
+		return err
+	}
+	if err := htmlMd.Convert(rendered.Bytes(), &actualHTML); err != nil {
+		return err
+	}
+	if expectedHTML.String() != actualHTML.String() {
+		return fmt.Errorf("HTML differs\nrendered:\n%s\nexpected: %s\nactual:   %s", rendered.String(), expectedHTML.String(), actualHTML.String())
+	}
+
+	return nil
+}
+
+// specTree summarizes the structure and content of a document, without source positions,
+// for comparing documents parsed from different sources.
+func specTree(doc ast.Node, source []byte) string {
+	var tree strings.Builder
+	// Adjacent text is merged, since it may be split differently in each source.
+	var pendingText strings.Builder
+	flushText := func() {
+		if pendingText.Len() > 0 {
+			tree.WriteString(strconv.Quote(pendingText.String()))
+			pendingText.Reset()
+		}
+	}
+
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		switch n := n.(type) {
+		case *ast.Text:
+			if entering {
+				pendingText.Write(n.Segment.Value(source))
+				if n.SoftLineBreak() {
+					pendingText.WriteString("\n")
+				}
+				if n.HardLineBreak() {
+					pendingText.WriteString("<br>")
+				}
+			}
+			return ast.WalkContinue, nil
+		case *ast.String:
+			if entering {
+				pendingText.Write(n.Value)
+			}
+			return ast.WalkContinue, nil
+		}
+
+		// Link reference definitions leave an empty text block behind, which isn't rendered.
+		if n.Kind() == ast.KindTextBlock && !n.HasChildren() {
+			return ast.WalkSkipChildren, nil
+		}
+
+		flushText()
+		if !entering {
+			tree.WriteString(")")
+			return ast.WalkContinue, nil
+		}
+
+		tree.WriteString("(" + n.Kind().String())
+		for _, detail := range specNodeDetails(n, source) {
+			tree.WriteString(" " + strconv.Quote(detail))
+		}
+		return ast.WalkContinue, nil
+	})
+
+	flushText()
+	return tree.String()
+}
+
+// specNodeDetails returns the parts of a node that aren't in its children.
+func specNodeDetails(node ast.Node, source []byte) []string {
+	switch n := node.(type) {
+	case *ast.Heading:
+		return []string{strconv.Itoa(n.Level)}
+	case *ast.Emphasis:
+		return []string{strconv.Itoa(n.Level)}
+	case *ast.List:
+		return []string{strconv.FormatBool(n.IsOrdered()), strconv.Itoa(n.Start), strconv.FormatBool(n.IsTight)}
+	case *ast.Link:
+		return []string{string(n.Destination), string(n.Title)}
+	case *ast.Image:
+		return []string{string(n.Destination), string(n.Title)}
+	case *ast.AutoLink:
+		return []string{string(n.URL(source))}
+	case *ast.FencedCodeBlock:
+		return []string{string(n.Language(source)), specLines(n, source)}
+	case *ast.CodeBlock, *ast.HTMLBlock:
+		return []string{specLines(n, source)}
+	case *ast.RawHTML:
+		var raw []string
+		for i := 0; i < n.Segments.Len(); i++ {
+			segment := n.Segments.At(i)
+			raw = append(raw, string(segment.Value(source)))
+		}
+		return raw
+	case *extension_ast.TaskCheckBox:
+		return []string{strconv.FormatBool(n.IsChecked)}
+	case *extension_ast.Table:
+		var alignments []string
+		for _, alignment := range n.Alignments {
+			alignments = append(alignments, alignment.String())
+		}
+		return alignments
+	}
+	return nil
+}
+
+func specLines(n ast.Node, source []byte) string {
+	var lines strings.Builder
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		lines.Write(line.Value(source))
+	}
+	return lines.String()
+}
+
+// loadSpecExamples loads the CommonMark spec examples, and the GFM, footnote, and definition list examples
+// from goldmark's extension tests.
+func loadSpecExamples(t *testing.T) []specExample {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/spec/commonmark.json")
+	require.NoError(t, err)
+
+	var commonmark []struct {
+		Markdown string `json:"markdown"`
+		Example  int    `json:"example"`
+		Section  string `json:"section"`
+	}
+	require.NoError(t, json.Unmarshal(data, &commonmark))
+
+	var examples []specExample
+	for _, example := range commonmark {
+		examples = append(examples, specExample{
+			Name:     fmt.Sprintf("commonmark/%d", example.Example),
+			Section:  example.Section,
+			Markdown: example.Markdown,
+		})
+	}
+
+	for _, name := range []string{"table", "strikethrough", "tasklist", "linkify"} {
+		examples = append(examples, loadExtensionExamples(t, name, "GFM "+name, extension.GFM)...)
+	}
+	examples = append(examples, loadExtensionExamples(t, "footnote", "Footnotes", extension.Footnote)...)
+	examples = append(examples, loadExtensionExamples(t, "definition_list", "Definition lists", extension.DefinitionList)...)
+	return examples
+}
+
+const (
+	extensionAttributeSeparator = "//- - - - - - - - -//"
+	extensionCaseSeparator      = "//= = = = = = = = = = = = = = = = = = = = = = = =//"
+)
+
+// loadExtensionExamples loads examples in goldmark's test case format, which is a number and description,
+// optional options, and the markdown and HTML separated by //- - -// lines.
+func loadExtensionExamples(t *testing.T, name string, section string, extensions ...goldmark.Extender) []specExample {
+	t.Helper()
+
+	file, err := os.Open(filepath.Join("testdata/spec", name+".txt"))
+	require.NoError(t, err)
+	defer file.Close()
+
+	var examples []specExample
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		header := strings.TrimSpace(scanner.Text())
+		if header == "" {
+			continue
+		}
+		number, _, _ := strings.Cut(header, ":")
+
+		escape := false
+		for scanner.Scan() && scanner.Text() != extensionAttributeSeparator {
+			escape = escape || strings.Contains(scanner.Text(), `"enableEscape": true`)
+		}
+
+		var markdown []string
+		for scanner.Scan() && scanner.Text() != extensionAttributeSeparator {
+			markdown = append(markdown, scanner.Text())
+		}
+		// Skip the expected HTML, since the round-trip is compared to goldmark's own HTML.
+		for scanner.Scan() && scanner.Text() != extensionCaseSeparator {
+		}
+
+		source := strings.Join(markdown, "\n")
+		if escape {
+			source = strings.ReplaceAll(source, `\t`, "\t")
+		}
+		examples = append(examples, specExample{
+			Name:       fmt.Sprintf("%s/%s", name, strings.TrimSpace(number)),
+			Section:    section,
+			Markdown:   source,
+			Extensions: extensions,
+		})
+	}
+	require.NoError(t, scanner.Err())
+
+	return examples
+}
+
+// loadSpecBaseline loads the names of the examples that are known to round-trip.
+func loadSpecBaseline(t *testing.T) map[string]bool {
+	t.Helper()
+
+	baseline := map[string]bool{}
+	data, err := os.ReadFile(specBaseline)
+	if os.IsNotExist(err) {
+		return baseline
+	}
+	require.NoError(t, err)
+
+	names := strings.Fields(string(data))
+	sort.Strings(names)
+	for _, name := range names {
+		baseline[name] = true
+	}
+	return baseline
+}
@@ -0,0 +1,311 @@
+package mdrender
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Style configures the markdown syntax the renderer writes, for matching a repo's lint or formatter rules.
+// The zero value of each field keeps the default style.
+type Style struct {
+	// BulletMarker is the marker for unordered list items: -, *, or +.
+	// Defaults to the marker each list was parsed with.
+	BulletMarker byte
+	// EmphasisMarker is the marker for emphasis: _ or *. Defaults to _.
+	EmphasisMarker byte
+	// StrongMarker is the marker for strong emphasis, which is written twice: * or _. Defaults to *.
+	StrongMarker byte
+	// OrderedNumbering is how ordered list items are numbered. Defaults to NumberingOnes.
+	OrderedNumbering OrderedNumbering
+	// HeadingStyle is the syntax for headings. Defaults to HeadingATX.
+	HeadingStyle HeadingStyle
+	// FenceChar is the character for code fences: ` or ~. Defaults to `.
+	FenceChar byte
+	// WrapWidth wraps paragraphs to lines of at most this many characters, where possible.
+	// Defaults to 0, which does not wrap.
+	WrapWidth int
+}
+
+// OrderedNumbering is how ordered list items are numbered.
+type OrderedNumbering int
+
+const (
+	// NumberingOnes gives every item the list's start number, usually 1.
+	NumberingOnes OrderedNumbering = iota
+	// NumberingIncrement counts up from the list's start number.
+	NumberingIncrement
+)
+
+// HeadingStyle is the syntax for headings.
+type HeadingStyle int
+
+const (
+	// HeadingATX writes headings with # markers.
+	HeadingATX HeadingStyle = iota
+	// HeadingSetext underlines level 1 and 2 headings with = and -, and uses # markers for the rest.
+	HeadingSetext
+)
+
+type withStyle struct {
+	style Style
+}
+
+var _ Option = (*withStyle)(nil)
+
+func (o *withStyle) SetMarkdownOption(c *Config) {
+	c.Style = o.style
+}
+
+// WithStyle sets the whole output style at once.
+func WithStyle(style Style) Option {
+	return &withStyle{style: style}
+}
+
+type withBulletMarker struct {
+	marker byte
+}
+
+var _ Option = (*withBulletMarker)(nil)
+
+func (o *withBulletMarker) SetMarkdownOption(c *Config) {
+	c.Style.BulletMarker = o.marker
+}
+
+// WithBulletMarker sets the marker for unordered list items: -, *, or +.
+func WithBulletMarker(marker byte) Option {
+	return &withBulletMarker{marker: marker}
+}
+
+type withEmphasisMarker struct {
+	marker byte
+}
+
+var _ Option = (*withEmphasisMarker)(nil)
+
+func (o *withEmphasisMarker) SetMarkdownOption(c *Config) {
+	c.Style.EmphasisMarker = o.marker
+}
+
+// WithEmphasisMarker sets the marker for emphasis: _ or *.
+func WithEmphasisMarker(marker byte) Option {
+	return &withEmphasisMarker{marker: marker}
+}
+
+type withStrongMarker struct {
+	marker byte
+}
+
+var _ Option = (*withStrongMarker)(nil)
+
+func (o *withStrongMarker) SetMarkdownOption(c *Config) {
+	c.Style.StrongMarker = o.marker
+}
+
+// WithStrongMarker sets the marker for strong emphasis: * or _.
+func WithStrongMarker(marker byte) Option {
+	return &withStrongMarker{marker: marker}
+}
+
+type withOrderedNumbering struct {
+	numbering OrderedNumbering
+}
+
+var _ Option = (*withOrderedNumbering)(nil)
+
+func (o *withOrderedNumbering) SetMarkdownOption(c *Config) {
+	c.Style.OrderedNumbering = o.numbering
+}
+
+// WithOrderedNumbering sets how ordered list items are numbered.
+func WithOrderedNumbering(numbering OrderedNumbering) Option {
+	return &withOrderedNumbering{numbering: numbering}
+}
+
+type withHeadingStyle struct {
+	style HeadingStyle
+}
+
+var _ Option = (*withHeadingStyle)(nil)
+
+func (o *withHeadingStyle) SetMarkdownOption(c *Config) {
+	c.Style.HeadingStyle = o.style
+}
+
+// WithHeadingStyle sets the syntax for headings.
+func WithHeadingStyle(style HeadingStyle) Option {
+	return &withHeadingStyle{style: style}
+}
+
+type withFenceChar struct {
+	char byte
+}
+
+var _ Option = (*withFenceChar)(nil)
+
+func (o *withFenceChar) SetMarkdownOption(c *Config) {
+	c.Style.FenceChar = o.char
+}
+
+// WithFenceChar sets the character for code fences: ` or ~.
+func WithFenceChar(char byte) Option {
+	return &withFenceChar{char: char}
+}
+
+type withWrapWidth struct {
+	width int
+}
+
+var _ Option = (*withWrapWidth)(nil)
+
+func (o *withWrapWidth) SetMarkdownOption(c *Config) {
+	c.Style.WrapWidth = o.width
+}
+
+// WithWrapWidth wraps paragraphs to lines of at most width characters, where possible.
+func WithWrapWidth(width int) Option {
+	return &withWrapWidth{width: width}
+}
+
+func (s Style) emphasisMarker() byte {
+	if s.EmphasisMarker == 0 {
+		return '_'
+	}
+	return s.EmphasisMarker
+}
+
+func (s Style) strongMarker() byte {
+	if s.StrongMarker == 0 {
+		return '*'
+	}
+	return s.StrongMarker
+}
+
+func (s Style) fenceChar() byte {
+	if s.FenceChar == 0 {
+		return '`'
+	}
+	return s.FenceChar
+}
+
+// codeFence returns a fence that is longer than any run of the fence character that starts a line in the code.
+func (s Style) codeFence(code []byte) string {
+	char := s.fenceChar()
+
+	length := 3
+	for _, line := range bytes.Split(code, []byte("\n")) {
+		line = bytes.TrimLeft(line, " ")
+		run := 0
+		for run < len(line) && line[run] == char {
+			run++
+		}
+		if run >= length {
+			length = run + 1
+		}
+	}
+
+	return strings.Repeat(string(char), length)
+}
+
+// unsafeLineStart matches words that would start a new block if a wrapped line began with them.
+var unsafeLineStart = regexp.MustCompile("^(#{1,6}$|[-+*]$|[0-9]{1,9}[.)]$|>|<|=+$|-+$|```|~~~|\\|)")
+
+// wrapText reflows paragraph text to lines of at most width characters, with indent before each new line.
+// Hard line breaks are kept, and words that would start a new block are never wrapped to the start of a line.
+func wrapText(text string, width int, indent string) string {
+	var out strings.Builder
+
+	lines := strings.Split(text, "\n")
+	var words []string
+	for i, line := range lines {
+		words = append(words, splitWords(line)...)
+
+		hardBreak := strings.HasSuffix(line, "\\") || strings.HasSuffix(line, "  ")
+		if !hardBreak && i < len(lines)-1 {
+			continue
+		}
+
+		writeWrapped(&out, words, width, indent)
+		words = nil
+
+		if i < len(lines)-1 {
+			// Keep the hard break's trailing spaces.
+			if strings.HasSuffix(line, "  ") {
+				out.WriteString("  ")
+			}
+			out.WriteString("\n")
+			out.WriteString(indent)
+		}
+	}
+
+	return out.String()
+}
+
+// writeWrapped writes words separated by spaces, starting a new line before a word that won't fit.
+func writeWrapped(out *strings.Builder, words []string, width int, indent string) {
+	lineLength := utf8.RuneCountInString(indent)
+	lineWords := 0
+
+	for _, word := range words {
+		wordLength := utf8.RuneCountInString(word)
+
+		if lineWords > 0 {
+			if lineLength+1+wordLength > width && !unsafeLineStart.MatchString(word) {
+				out.WriteString("\n")
+				out.WriteString(indent)
+				lineLength = utf8.RuneCountInString(indent)
+				lineWords = 0
+			} else {
+				out.WriteByte(' ')
+				lineLength++
+			}
+		}
+
+		out.WriteString(word)
+		lineLength += wordLength
+		lineWords++
+	}
+}
+
+// splitWords splits a line at spaces, except for spaces inside code spans, which are significant.
+func splitWords(line string) []string {
+	var words []string
+	var word strings.Builder
+	// The length of the backtick run that opened the current code span, or 0 outside of code.
+	codeFence := 0
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+
+		if c == '`' {
+			run := 1
+			for i+run < len(line) && line[i+run] == '`' {
+				run++
+			}
+			if codeFence == 0 && strings.Contains(line[i+run:], line[i:i+run]) {
+				codeFence = run
+			} else if codeFence == run {
+				codeFence = 0
+			}
+			word.WriteString(line[i : i+run])
+			i += run - 1
+			continue
+		}
+
+		if c == ' ' && codeFence == 0 {
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			continue
+		}
+
+		word.WriteByte(c)
+	}
+
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words
+}
@@ -0,0 +1,103 @@
+// Package mdwiki adds support for Obsidian-style [[wikilinks]] and ![[embeds]] to goldmark.
+package mdwiki
+
+import (
+	"strconv"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/util"
+)
+
+// Kind is the kind of wikilink AST nodes.
+var Kind = ast.NewNodeKind("WikiLink")
+
+// Node is a [[wikilink]] or ![[embed]] in a goldmark Markdown document.
+// Its child is the text shown for the link, which is the alias if there is one, or the target and heading.
+type Node struct {
+	ast.BaseInline
+
+	// Target is the page being linked to, like "Page" in [[Page#Heading|alias]].
+	// It is empty for links to a heading in the same page, like [[#Heading]].
+	Target []byte
+	// Heading is the heading or ^block in the page being linked to, like "Heading" in [[Page#Heading]], or nil.
+	Heading []byte
+	// Alias is the text to show instead of the target, like "alias" in [[Page|alias]], or nil.
+	Alias []byte
+	// Embed is true for embeds like ![[Page]], which show the target's contents instead of linking to it.
+	Embed bool
+}
+
+var _ ast.Node = &Node{}
+
+// Kind reports the kind of wikilink nodes.
+func (*Node) Kind() ast.NodeKind { return Kind }
+
+// Dump dumps the contents of Node to stdout for debugging.
+func (n *Node) Dump(src []byte, level int) {
+	ast.DumpHelper(n, src, level, map[string]string{
+		"Target":  string(n.Target),
+		"Heading": string(n.Heading),
+		"Alias":   string(n.Alias),
+		"Embed":   strconv.FormatBool(n.Embed),
+	}, nil)
+}
+
+// HTMLRenderer renders wikilinks and embeds as plain HTML links to their target.
+type HTMLRenderer struct{}
+
+var _ renderer.NodeRenderer = &HTMLRenderer{}
+
+func (r *HTMLRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(Kind, r.renderWikiLink)
+}
+
+func (r *HTMLRenderer) renderWikiLink(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		_, _ = w.WriteString("</a>")
+		return ast.WalkContinue, nil
+	}
+
+	n, _ := node.(*Node)
+	href := n.Target
+	if n.Heading != nil {
+		href = append(append(append([]byte{}, href...), '#'), n.Heading...)
+	}
+	_, _ = w.WriteString(`<a href="`)
+	_, _ = w.Write(util.EscapeHTML(util.URLEscape(href, true)))
+	_, _ = w.WriteString(`">`)
+	return ast.WalkContinue, nil
+}
+
+// Extender extends a goldmark Markdown object with support for parsing
+// [[wikilinks]] and ![[embeds]], and rendering them as HTML links.
+//
+// Install it on your Markdown object upon creation.
+//
+//	goldmark.New(
+//	  goldmark.WithExtensions(
+//	    // ...
+//	    &mdwiki.Extender{},
+//	  ),
+//	  // ...
+//	)
+type Extender struct{}
+
+var _ goldmark.Extender = (*Extender)(nil)
+
+// Extend extends the provided goldmark Markdown object with support for wikilinks.
+func (e *Extender) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		// Parse wikilinks before the link parser, which would otherwise see [[Page]] as text in brackets.
+		parser.WithInlineParsers(
+			util.Prioritized(&Parser{}, 199),
+		),
+	)
+	m.Renderer().AddOptions(
+		renderer.WithNodeRenderers(
+			util.Prioritized(&HTMLRenderer{}, 500),
+		),
+	)
+}
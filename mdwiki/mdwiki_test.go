@@ -0,0 +1,70 @@
+package mdwiki_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+
+	"github.com/will-wow/larkdown/internal/test"
+	"github.com/will-wow/larkdown/mdwiki"
+)
+
+func TestParseWikiLink(t *testing.T) {
+	tests := []struct {
+		name     string
+		markdown string
+		expected mdwiki.Node
+		text     string
+	}{
+		{"page", "[[Page]]", mdwiki.Node{Target: []byte("Page")}, "Page"},
+		{"alias", "[[Page|the page]]", mdwiki.Node{Target: []byte("Page"), Alias: []byte("the page")}, "the page"},
+		{"heading", "[[Page#Heading]]", mdwiki.Node{Target: []byte("Page"), Heading: []byte("Heading")}, "Page#Heading"},
+		{"same page heading", "[[#Heading]]", mdwiki.Node{Heading: []byte("Heading")}, "#Heading"},
+		{
+			"heading and alias",
+			"[[Page#^block | alias ]]",
+			mdwiki.Node{Target: []byte("Page"), Heading: []byte("^block"), Alias: []byte("alias")},
+			"alias",
+		},
+		{"embed", "![[image.png]]", mdwiki.Node{Target: []byte("image.png"), Embed: true}, "image.png"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, source := test.TreeFromMd(t, "See "+tt.markdown+" here.", goldmark.WithExtensions(&mdwiki.Extender{}))
+
+			link, ok := doc.FirstChild().FirstChild().NextSibling().(*mdwiki.Node)
+			require.True(t, ok, "expected a wikilink node")
+			require.Equal(t, tt.expected.Target, link.Target)
+			require.Equal(t, tt.expected.Heading, link.Heading)
+			require.Equal(t, tt.expected.Alias, link.Alias)
+			require.Equal(t, tt.expected.Embed, link.Embed)
+			require.Equal(t, tt.text, string(link.Text(source)))
+			require.Equal(t, " here.", string(link.NextSibling().Text(source)))
+		})
+	}
+
+	t.Run("leaves other brackets alone", func(t *testing.T) {
+		for _, markdown := range []string{"[[]]", "[[#]]", "[[Page]", "[[a [b] c]]", "[link](/url)", "![image](/url)"} {
+			doc, _ := test.TreeFromMd(t, markdown, goldmark.WithExtensions(&mdwiki.Extender{}))
+
+			_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+				require.NotEqual(t, mdwiki.Kind, n.Kind(), markdown)
+				return ast.WalkContinue, nil
+			})
+		}
+	})
+}
+
+func TestRenderWikiLinkHTML(t *testing.T) {
+	md := goldmark.New(goldmark.WithExtensions(&mdwiki.Extender{}))
+
+	var html bytes.Buffer
+	err := md.Convert([]byte("[[My Page#Part|alias]] and ![[image.png]]"), &html)
+	require.NoError(t, err)
+
+	require.Equal(t, "<p><a href=\"My%20Page#Part\">alias</a> and <a href=\"image.png\">image.png</a></p>\n", html.String())
+}
@@ -0,0 +1,72 @@
+package mdwiki
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// Parser is an inline parser for [[wikilinks]] and ![[embeds]].
+type Parser struct{}
+
+var _ parser.InlineParser = (*Parser)(nil)
+
+func (p *Parser) Trigger() []byte {
+	return []byte{'[', '!'}
+}
+
+func (p *Parser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+
+	embed := false
+	open := []byte("[[")
+	if len(line) > 0 && line[0] == '!' {
+		embed = true
+		open = []byte("![[")
+	}
+	if !bytes.HasPrefix(line, open) {
+		return nil
+	}
+
+	// The link must be closed on the same line, and can't contain other brackets.
+	contents := line[len(open):]
+	end := bytes.Index(contents, []byte("]]"))
+	if end <= 0 || bytes.ContainsAny(contents[:end], "[]") {
+		return nil
+	}
+	contents = contents[:end]
+	start := segment.Start + len(open)
+
+	n := &Node{Embed: embed}
+
+	// The displayed text is the alias, or the whole link without the alias.
+	display := text.NewSegment(start, start+end)
+	target := contents
+	if i := bytes.IndexByte(contents, '|'); i >= 0 {
+		target = contents[:i]
+		if alias := bytes.TrimSpace(contents[i+1:]); len(alias) > 0 {
+			n.Alias = alias
+			aliasStart := start + i + 1 + len(contents[i+1:]) - len(bytes.TrimLeft(contents[i+1:], " \t"))
+			display = text.NewSegment(aliasStart, aliasStart+len(alias))
+		} else {
+			display = display.WithStop(start + i)
+		}
+	}
+
+	if i := bytes.IndexByte(target, '#'); i >= 0 {
+		if heading := bytes.TrimSpace(target[i+1:]); len(heading) > 0 {
+			n.Heading = heading
+		}
+		target = target[:i]
+	}
+	n.Target = bytes.TrimSpace(target)
+	if len(n.Target) == 0 && len(n.Heading) == 0 {
+		return nil
+	}
+
+	n.AppendChild(n, ast.NewTextSegment(display))
+	block.Advance(len(open) + end + len("]]"))
+	return n
+}
@@ -0,0 +1,299 @@
+package larkdown
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark/ast"
+	extension_ast "github.com/yuin/goldmark/extension/ast"
+
+	"github.com/will-wow/larkdown/gmast"
+)
+
+// TimeLayouts are the layouts DecodeTable tries, in order, when converting a cell to a time.Time.
+// Use a `layout` struct tag to parse a field with a specific layout instead.
+var TimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// DecodeTable decodes a table node into a slice of structs, one per row.
+//
+// Columns are matched to struct fields by the field's `larkdown` tag, or by its name, ignoring case.
+// Cells are converted to the type of the field, which may be a string, int, uint, float, bool,
+// time.Time, or a pointer to one of those to leave blank cells as nil.
+//
+//	type Comment struct {
+//		Name   string    `larkdown:"Name"`
+//		Rating int       `larkdown:"Stars"`
+//		Date   time.Time `larkdown:"Posted" layout:"Jan 2, 2006"`
+//	}
+//
+//	comments, err := larkdown.Find(doc, source, query, larkdown.DecodeTable[Comment])
+//
+// Cells that fail to convert are reported as *TableCellError, with the row and column of the cell.
+func DecodeTable[T any](node ast.Node, source []byte) ([]T, error) {
+	out := []T{}
+	err := decodeTableInto(node, source, reflect.ValueOf(&out).Elem())
+	return out, err
+}
+
+// decodeTableInto decodes a table node into a slice of structs.
+func decodeTableInto(node ast.Node, source []byte, slice reflect.Value) error {
+	table, ok := node.(*extension_ast.Table)
+	if !ok {
+		return fmt.Errorf("expected table node, got %s", node.Kind())
+	}
+
+	elem := slice.Type().Elem()
+	if elem.Kind() != reflect.Struct {
+		return fmt.Errorf("expected a slice of structs, got %s", slice.Type())
+	}
+
+	// Map each column to a struct field index, or -1 if no field matches.
+	headers := []string{}
+	fields := []int{}
+	if header, ok := table.FirstChild().(*extension_ast.TableHeader); ok {
+		gmast.ForEachChild(header, source, func(cell ast.Node, index int) {
+			name := string(cell.Text(source))
+			if name == "" {
+				// If there's no header, then just use the column index
+				name = strconv.Itoa(index)
+			}
+			headers = append(headers, name)
+			fields = append(fields, fieldForColumn(elem, name))
+		})
+	}
+
+	rows := reflect.MakeSlice(slice.Type(), 0, table.ChildCount())
+	errs := []error{}
+	rowIndex := 0
+
+	gmast.ForEachChild(table, source, func(row ast.Node, _ int) {
+		if row.Kind() == extension_ast.KindTableHeader {
+			return
+		}
+		rowIndex++
+
+		value := reflect.New(elem).Elem()
+		gmast.ForEachChild(row, source, func(cell ast.Node, column int) {
+			if column >= len(fields) || fields[column] == -1 {
+				return
+			}
+
+			field := elem.Field(fields[column])
+			text := string(cell.Text(source))
+
+			err := setCell(value.Field(fields[column]), field, text)
+			if err != nil {
+				errs = append(errs, &TableCellError{
+					Row:    rowIndex,
+					Column: column + 1,
+					Header: headers[column],
+					Value:  text,
+					Err:    err,
+				})
+			}
+		})
+
+		rows = reflect.Append(rows, value)
+	})
+
+	slice.Set(rows)
+
+	return errors.Join(errs...)
+}
+
+// fieldForColumn finds the index of the struct field for a column header, or -1 if there is none.
+func fieldForColumn(structType reflect.Type, header string) int {
+	for i := 0; i < structType.NumField(); i++ {
+		name, ok := columnName(structType.Field(i))
+		if ok && strings.EqualFold(name, header) {
+			return i
+		}
+	}
+	return -1
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// setCell converts a cell's text to the type of a field, and sets the field.
+func setCell(value reflect.Value, field reflect.StructField, text string) error {
+	text = strings.TrimSpace(text)
+
+	if value.Kind() == reflect.Pointer {
+		// Leave blank cells as nil.
+		if text == "" {
+			return nil
+		}
+
+		ptr := reflect.New(value.Type().Elem())
+		if err := setCell(ptr.Elem(), field, text); err != nil {
+			return err
+		}
+		value.Set(ptr)
+		return nil
+	}
+
+	if value.Type() == timeType {
+		if text == "" {
+			return nil
+		}
+
+		parsed, err := parseTime(text, field.Tag.Get("layout"))
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(parsed))
+		return nil
+	}
+
+	if value.Kind() == reflect.String {
+		value.SetString(text)
+		return nil
+	}
+
+	// Leave blank cells as the zero value.
+	if text == "" {
+		return nil
+	}
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(text, 10, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot convert to %s: %w", value.Type(), err)
+		}
+		value.SetInt(n)
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(text, 10, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot convert to %s: %w", value.Type(), err)
+		}
+		value.SetUint(n)
+
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(text, value.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("cannot convert to %s: %w", value.Type(), err)
+		}
+		value.SetFloat(n)
+
+	case reflect.Bool:
+		b, err := parseBool(text)
+		if err != nil {
+			return err
+		}
+		value.SetBool(b)
+
+	default:
+		return fmt.Errorf("unsupported field type %s", value.Type())
+	}
+
+	return nil
+}
+
+// parseBool parses the usual strconv values, plus yes/no and checkmarks that are common in tables.
+func parseBool(text string) (bool, error) {
+	switch strings.ToLower(text) {
+	case "yes", "y", "x", "✓", "✔":
+		return true, nil
+	case "no", "n", "-":
+		return false, nil
+	}
+
+	b, err := strconv.ParseBool(text)
+	if err != nil {
+		return false, fmt.Errorf("cannot convert to bool: %w", err)
+	}
+	return b, nil
+}
+
+// parseTime parses a time with the given layout, or with each of the TimeLayouts.
+func parseTime(text string, layout string) (time.Time, error) {
+	if layout != "" {
+		parsed, err := time.Parse(layout, text)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("cannot convert to time: %w", err)
+		}
+		return parsed, nil
+	}
+
+	for _, layout := range TimeLayouts {
+		parsed, err := time.Parse(layout, text)
+		if err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot convert to time: no layout matched")
+}
+
+// formatCell formats a struct field as the text of a table cell. It is the reverse of setCell.
+func formatCell(value reflect.Value, field reflect.StructField) string {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return ""
+		}
+		return formatCell(value.Elem(), field)
+	}
+
+	if value.Type() == timeType {
+		t, _ := value.Interface().(time.Time)
+		if t.IsZero() {
+			return ""
+		}
+
+		layout := field.Tag.Get("layout")
+		if layout == "" {
+			layout = TimeLayouts[0]
+			// Use a date-only layout for times at midnight in their own location, like those parsed from dates.
+			if t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+				layout = "2006-01-02"
+			}
+		}
+		return t.Format(layout)
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(value.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(value.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(value.Float(), 'f', -1, value.Type().Bits())
+	case reflect.Bool:
+		return strconv.FormatBool(value.Bool())
+	}
+
+	return fmt.Sprint(value.Interface())
+}
+
+// TableCellError is returned when a table cell can't be converted to the type of its struct field.
+type TableCellError struct {
+	// The 1-based row in the table body, not counting the header.
+	Row int
+	// The 1-based column.
+	Column int
+	// The column header.
+	Header string
+	// The text of the cell.
+	Value string
+	Err   error
+}
+
+func (e *TableCellError) Error() string {
+	return fmt.Sprintf("row %d, column %d (%s): %q %s", e.Row, e.Column, e.Header, e.Value, e.Err)
+}
+
+func (e *TableCellError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,136 @@
+package larkdown_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+
+	"github.com/will-wow/larkdown"
+	"github.com/will-wow/larkdown/internal/test"
+	"github.com/will-wow/larkdown/match"
+)
+
+type review struct {
+	Name     string
+	Stars    int       `larkdown:"Rating"`
+	Price    float64   `larkdown:"Cost ($)"`
+	Again    bool      `larkdown:"Would Repeat"`
+	Date     time.Time `larkdown:"Visited" layout:"Jan 2, 2006"`
+	Reviewed *time.Time
+	Ignored  string `larkdown:"-"`
+}
+
+func TestDecodeTable(t *testing.T) {
+	tableQuery := []match.Node{match.Table{}}
+
+	t.Run("converts cells to field types", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+		| name  | Rating | Cost ($) | Would Repeat | Visited     | Reviewed   | Extra |
+		| ----- | ------ | -------- | ------------ | ----------- | ---------- | ----- |
+		| Alice | 5      | 12.50    | yes          | Mar 4, 2023 | 2023-03-05 | x     |
+		| Bob   |        | 8        | false        | Jan 2, 2006 |            |       |
+		`, goldmark.WithExtensions(extension.Table))
+
+		reviews, err := larkdown.Find(doc, source, tableQuery, larkdown.DecodeTable[review])
+		require.NoError(t, err)
+
+		reviewed := time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC)
+		require.Equal(t, []review{
+			{
+				Name:     "Alice",
+				Stars:    5,
+				Price:    12.5,
+				Again:    true,
+				Date:     time.Date(2023, 3, 4, 0, 0, 0, 0, time.UTC),
+				Reviewed: &reviewed,
+			},
+			{
+				Name:  "Bob",
+				Price: 8,
+				Date:  time.Date(2006, 1, 2, 0, 0, 0, 0, time.UTC),
+			},
+		}, reviews)
+	})
+
+	t.Run("reports the position of bad cells", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+		| Name  | Rating | Would Repeat |
+		| ----- | ------ | ------------ |
+		| Alice | 5      | yes          |
+		| Bob   | five   | maybe        |
+		`, goldmark.WithExtensions(extension.Table))
+
+		reviews, err := larkdown.Find(doc, source, tableQuery, larkdown.DecodeTable[review])
+		require.Len(t, reviews, 2, "still decodes the other cells")
+
+		var cellErr *larkdown.TableCellError
+		require.True(t, errors.As(err, &cellErr))
+		require.Equal(t, 2, cellErr.Row)
+		require.Equal(t, 2, cellErr.Column)
+		require.Equal(t, "Rating", cellErr.Header)
+
+		require.ErrorContains(t, err, `row 2, column 2 (Rating): "five" cannot convert to int`)
+		require.ErrorContains(t, err, `row 2, column 3 (Would Repeat): "maybe" cannot convert to bool`)
+	})
+
+	t.Run("round-trips through Marshal", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+		| Name  | Rating | Visited     | Reviewed |
+		| ----- | ------ | ----------- | -------- |
+		| Alice | 5      | Mar 4, 2023 |          |
+		`, goldmark.WithExtensions(extension.Table))
+
+		var out struct {
+			Reviews []review `larkdown:".table"`
+		}
+		err := larkdown.Unmarshal(doc, source, &out)
+		require.NoError(t, err)
+
+		reviewed := time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC)
+		out.Reviews[0].Stars = 4
+		out.Reviews[0].Reviewed = &reviewed
+
+		source, err = larkdown.Marshal(doc, source, out)
+		require.NoError(t, err)
+
+		rows, err := larkdown.Find(doc, source, tableQuery, larkdown.DecodeTableToMap)
+		require.NoError(t, err)
+		require.Equal(t, []map[string]string{
+			{"Name": "Alice", "Rating": "4", "Visited": "Mar 4, 2023", "Reviewed": "2023-03-05"},
+		}, rows)
+	})
+	t.Run("writes midnight in a time's own location as a date", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+		| Name  | Reviewed |
+		| ----- | -------- |
+		| Alice |          |
+		| Bob   |          |
+		`, goldmark.WithExtensions(extension.Table))
+
+		var out struct {
+			Reviews []review `larkdown:".table"`
+		}
+		err := larkdown.Unmarshal(doc, source, &out)
+		require.NoError(t, err)
+
+		tokyo := time.FixedZone("JST", 9*60*60)
+		localMidnight := time.Date(2023, 3, 5, 0, 0, 0, 0, tokyo)
+		utcMidnight := time.Date(2023, 3, 5, 0, 0, 0, 0, time.UTC).In(tokyo)
+		out.Reviews[0].Reviewed = &localMidnight
+		out.Reviews[1].Reviewed = &utcMidnight
+
+		source, err = larkdown.Marshal(doc, source, out)
+		require.NoError(t, err)
+
+		rows, err := larkdown.Find(doc, source, tableQuery, larkdown.DecodeTableToMap)
+		require.NoError(t, err)
+		require.Equal(t, []map[string]string{
+			{"Name": "Alice", "Reviewed": "2023-03-05"},
+			{"Name": "Bob", "Reviewed": "2023-03-05T09:00:00+09:00"},
+		}, rows)
+	})
+}
@@ -0,0 +1,220 @@
+package larkdown
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	extension_ast "github.com/yuin/goldmark/extension/ast"
+)
+
+// TreeNode is a node in a generic document tree built by ToTree.
+// It is one of *TreeBranch, TreeList, TreeTable, or TreeText.
+type TreeNode interface {
+	json.Marshaler
+	isTreeNode()
+}
+
+// TreeBranch is a heading, and all the nodes below it until the next heading of the same or higher level.
+// The root of a tree is a branch with level 0 and no name.
+type TreeBranch struct {
+	// The heading level, or 0 for the document root.
+	Level int
+	// The text of the heading.
+	Name string
+	// The nodes and sub-branches under the heading.
+	Children []TreeNode
+}
+
+// TreeList is the items of a list.
+type TreeList struct {
+	Ordered bool
+	Items   []string
+}
+
+// TreeTable is the rows of a table, as maps of column headers to cell text.
+type TreeTable []map[string]string
+
+// TreeText is the text of a paragraph or any other block.
+type TreeText string
+
+func (*TreeBranch) isTreeNode() {}
+func (TreeList) isTreeNode()    {}
+func (TreeTable) isTreeNode()   {}
+func (TreeText) isTreeNode()    {}
+
+// ToTree converts a document into a generic tree, where headings are branches that hold
+// the content below them, lists are arrays, tables are arrays of maps, and other blocks are strings.
+//
+// The tree marshals to JSON in the same shape as the README example, which is useful for
+// piping documents into tools like jq without writing queries first.
+func ToTree(doc ast.Node, source []byte) (*TreeBranch, error) {
+	root := &TreeBranch{Children: []TreeNode{}}
+
+	// The stack of open branches, starting with the root.
+	branches := []*TreeBranch{root}
+
+	for node := doc.FirstChild(); node != nil; node = node.NextSibling() {
+		if heading, ok := node.(*ast.Heading); ok {
+			// Close any branches at the same or a lower level.
+			for len(branches) > 1 && branches[len(branches)-1].Level >= heading.Level {
+				branches = branches[:len(branches)-1]
+			}
+
+			branch := &TreeBranch{
+				Level:    heading.Level,
+				Name:     string(heading.Text(source)),
+				Children: []TreeNode{},
+			}
+
+			parent := branches[len(branches)-1]
+			parent.Children = append(parent.Children, branch)
+			branches = append(branches, branch)
+			continue
+		}
+
+		treeNode, err := toTreeNode(node, source)
+		if err != nil {
+			return nil, err
+		}
+		if treeNode == nil {
+			continue
+		}
+
+		parent := branches[len(branches)-1]
+		parent.Children = append(parent.Children, treeNode)
+	}
+
+	return root, nil
+}
+
+// toTreeNode decodes a non-heading block, or returns nil for blocks with no content.
+func toTreeNode(node ast.Node, source []byte) (TreeNode, error) {
+	switch n := node.(type) {
+	case *ast.List:
+		items, err := DecodeListItems(n, source)
+		if err != nil {
+			return nil, err
+		}
+		if items == nil {
+			items = []string{}
+		}
+		return TreeList{Ordered: n.IsOrdered(), Items: items}, nil
+
+	case *extension_ast.Table:
+		rows, err := DecodeTableToMap(n, source)
+		if err != nil {
+			return nil, err
+		}
+		return TreeTable(rows), nil
+
+	case *ast.ThematicBreak:
+		return nil, nil
+	}
+
+	text := blockText(node, source)
+	if text == "" {
+		return nil, nil
+	}
+	return TreeText(text), nil
+}
+
+// blockText returns the text of a block. Blocks with raw lines, like code blocks, return their lines.
+func blockText(node ast.Node, source []byte) string {
+	if node.Type() == ast.TypeBlock && node.IsRaw() {
+		var buf bytes.Buffer
+		lines := node.Lines()
+		for i := 0; i < lines.Len(); i++ {
+			line := lines.At(i)
+			buf.Write(line.Value(source))
+		}
+		return strings.TrimRight(buf.String(), "\n")
+	}
+
+	return string(node.Text(source))
+}
+
+// MarshalJSON writes a branch as an object with the heading name as the key, and an array of
+// the heading and its children as the value.
+//
+// The root branch is written as one object with a key for each top-level heading, in document order.
+// Any content before the first heading is under the "" key, and repeated top-level headings share a key.
+func (b *TreeBranch) MarshalJSON() ([]byte, error) {
+	if b.Level == 0 {
+		return b.marshalRoot()
+	}
+
+	return json.Marshal(map[string][]any{b.Name: b.contents()})
+}
+
+// contents returns the heading line of a branch, followed by its children.
+func (b *TreeBranch) contents() []any {
+	heading := strings.Repeat("#", b.Level) + " " + b.Name
+	return append([]any{heading}, treeNodesToAny(b.Children)...)
+}
+
+// marshalRoot writes the root branch as an object of its top-level branches, keeping their order.
+func (b *TreeBranch) marshalRoot() ([]byte, error) {
+	keys := []string{}
+	values := map[string][]any{}
+	add := func(key string, contents ...any) {
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = append(values[key], contents...)
+	}
+
+	for _, child := range b.Children {
+		if branch, ok := child.(*TreeBranch); ok {
+			add(branch.Name, branch.contents()...)
+		} else {
+			add("", child)
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		contents, err := json.Marshal(values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(contents)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// MarshalJSON writes a list as an object with a "list" key.
+func (l TreeList) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string][]string{"list": l.Items})
+}
+
+// MarshalJSON writes a table as an object with a "table" key.
+func (t TreeTable) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string][]map[string]string{"table": t})
+}
+
+// MarshalJSON writes text as a string.
+func (t TreeText) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(t))
+}
+
+func treeNodesToAny(nodes []TreeNode) []any {
+	out := make([]any, len(nodes))
+	for i, node := range nodes {
+		out[i] = node
+	}
+	return out
+}
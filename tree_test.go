@@ -0,0 +1,97 @@
+package larkdown_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+
+	"github.com/will-wow/larkdown"
+	"github.com/will-wow/larkdown/internal/test"
+)
+
+func TestToTree(t *testing.T) {
+	t.Run("builds branches from headings", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+		Intro
+
+		### Deep
+
+		## Shallower
+
+		1. one
+		1. two
+
+		---
+
+		# Top
+
+		| a | b |
+		| - | - |
+		| 1 | 2 |
+
+		    func main() {}
+		`, goldmark.WithExtensions(extension.Table))
+
+		tree, err := larkdown.ToTree(doc, source)
+		require.NoError(t, err)
+
+		require.Equal(t, &larkdown.TreeBranch{
+			Children: []larkdown.TreeNode{
+				larkdown.TreeText("Intro"),
+				&larkdown.TreeBranch{Level: 3, Name: "Deep", Children: []larkdown.TreeNode{}},
+				&larkdown.TreeBranch{Level: 2, Name: "Shallower", Children: []larkdown.TreeNode{
+					larkdown.TreeList{Ordered: true, Items: []string{"one", "two"}},
+				}},
+				&larkdown.TreeBranch{Level: 1, Name: "Top", Children: []larkdown.TreeNode{
+					larkdown.TreeTable{{"a": "1", "b": "2"}},
+					larkdown.TreeText("func main() {}"),
+				}},
+			},
+		}, tree)
+	})
+
+	t.Run("marshals tables to JSON", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+		# Table
+
+		| a | b |
+		| - | - |
+		| 1 | 2 |
+		`, goldmark.WithExtensions(extension.Table))
+
+		tree, err := larkdown.ToTree(doc, source)
+		require.NoError(t, err)
+
+		out, err := json.Marshal(tree)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"Table": ["# Table", {"table": [{"a": "1", "b": "2"}]}]}`, string(out))
+	})
+
+	t.Run("marshals the root as an object of top-level headings", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+		Intro
+
+		# B
+
+		one
+
+		# A
+
+		two
+
+		# B
+
+		three
+		`)
+
+		tree, err := larkdown.ToTree(doc, source)
+		require.NoError(t, err)
+
+		out, err := json.Marshal(tree)
+		require.NoError(t, err)
+		require.Equal(t, `{"":["Intro"],"B":["# B","one","# B","three"],"A":["# A","two"]}`, string(out))
+	})
+}
@@ -0,0 +1,315 @@
+package larkdown
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"go.abhg.dev/goldmark/hashtag"
+
+	"github.com/will-wow/larkdown/match"
+	"github.com/will-wow/larkdown/mdwiki"
+	"github.com/will-wow/larkdown/query"
+)
+
+// NodeUnmarshaler is implemented by types that can decode themselves from a matched node.
+// Unmarshal uses it in preference to the default decoders.
+type NodeUnmarshaler interface {
+	UnmarshalMarkdown(node ast.Node, source []byte) error
+}
+
+// Unmarshal decodes a document into a struct, using `larkdown` struct tags to find each field.
+//
+// The tag holds a query in the syntax of match.Parse, optionally followed by comma-separated options:
+//
+//	type Recipe struct {
+//		Ingredients []string `larkdown:"## Ingredients > .list"`
+//		Tags        []string `larkdown:"## Tags > [#tag],all"`
+//		Notes       string   `larkdown:"## Notes > [kind:Paragraph],optional"`
+//		Author      string   `larkdown:"[frontmatter:author]"`
+//	}
+//
+// Options:
+//   - optional: do not report an error if the query does not match.
+//   - all: use the last matcher as a FindAll extractor, and decode every match into a slice.
+//
+// Frontmatter queries decode the value at the key into the field with the frontmatter's format,
+// for documents parsed with mdfront.Extender. Otherwise, the decoder is chosen from the field type:
+//   - NodeUnmarshaler: the field's UnmarshalMarkdown method.
+//   - string: DecodeText, DecodeTag for #tags, the target page for [[wikilinks]], or DecodeCode for code blocks.
+//   - []string: DecodeListItems.
+//   - structs and maps: DecodeCodeAs, for yaml, json, or toml code blocks, or DecodeListItemsAs for lists.
+//   - []ListItem: DecodeListTree.
+//   - []map[string]string: DecodeTableToMap.
+//   - []struct: DecodeTable, matching column headers to field names or `larkdown` tags.
+//
+// Fields that fail to match or decode are collected into an *UnmarshalError,
+// which unwraps to each field's underlying error, such as a *query.QueryError.
+func Unmarshal(doc ast.Node, source []byte, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("larkdown: Unmarshal expects a non-nil pointer to a struct, got %T", v)
+	}
+	rv = rv.Elem()
+	rt := rv.Type()
+
+	unmarshalErr := &UnmarshalError{}
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		tagValue, ok := field.Tag.Lookup("larkdown")
+		if !ok || tagValue == "-" || !field.IsExported() {
+			continue
+		}
+
+		tag, err := parseFieldTag(tagValue)
+		if err != nil {
+			unmarshalErr.add(field.Name, err)
+			continue
+		}
+
+		err = unmarshalField(doc, source, rv.Field(i), tag)
+		if err != nil {
+			unmarshalErr.add(field.Name, err)
+		}
+	}
+
+	if len(unmarshalErr.Errors) > 0 {
+		return unmarshalErr
+	}
+	return nil
+}
+
+// fieldTag is a parsed `larkdown` struct tag.
+type fieldTag struct {
+	query    []match.Node
+	optional bool
+	all      bool
+}
+
+// parseFieldTag parses a query and its trailing options from a struct tag.
+func parseFieldTag(tag string) (fieldTag, error) {
+	out := fieldTag{}
+
+	// Pull known options off the end, so queries may still contain commas.
+	for {
+		i := strings.LastIndexByte(tag, ',')
+		if i == -1 {
+			break
+		}
+
+		switch strings.TrimSpace(tag[i+1:]) {
+		case "optional":
+			out.optional = true
+		case "all":
+			out.all = true
+		default:
+			// Not an option, so the comma is part of the query.
+			i = -1
+		}
+		if i == -1 {
+			break
+		}
+		tag = tag[:i]
+	}
+
+	query, err := match.Parse(tag)
+	if err != nil {
+		return out, err
+	}
+	out.query = query
+
+	return out, nil
+}
+
+// unmarshalField finds and decodes the node for a single field.
+func unmarshalField(doc ast.Node, source []byte, field reflect.Value, tag fieldTag) error {
+	if tag.all {
+		return unmarshalAll(doc, source, field, tag)
+	}
+
+	found, err := query.QueryOne(doc, source, tag.query)
+	if err != nil {
+		var queryErr *query.QueryError
+		if tag.optional && errors.As(err, &queryErr) {
+			return nil
+		}
+		return err
+	}
+
+	// Frontmatter values are decoded with the frontmatter's own format, rather than by field type.
+	if frontmatter, ok := tag.query[len(tag.query)-1].(match.Frontmatter); ok {
+		return decodeFrontmatter(found, frontmatter.Key, field.Addr().Interface())
+	}
+
+	return decodeValue(found, source, field)
+}
+
+// unmarshalAll uses the last matcher as an extractor, and decodes every match into a slice field.
+func unmarshalAll(doc ast.Node, source []byte, field reflect.Value, tag fieldTag) error {
+	if field.Kind() != reflect.Slice {
+		return fmt.Errorf("the all option requires a slice field, got %s", field.Type())
+	}
+
+	matcher := tag.query[:len(tag.query)-1]
+	extractor := tag.query[len(tag.query)-1]
+
+	found, err := query.QueryAll(doc, source, matcher, extractor)
+	if err != nil {
+		var queryErr *query.QueryError
+		if tag.optional && errors.As(err, &queryErr) {
+			return nil
+		}
+		return err
+	}
+
+	slice := reflect.MakeSlice(field.Type(), len(found), len(found))
+	for i, node := range found {
+		err := decodeValue(node, source, slice.Index(i))
+		if err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+	}
+	field.Set(slice)
+
+	return nil
+}
+
+var nodeUnmarshalerType = reflect.TypeOf((*NodeUnmarshaler)(nil)).Elem()
+
+// decodeValue picks a decoder based on the type of the value, and sets the value.
+func decodeValue(node ast.Node, source []byte, value reflect.Value) error {
+	if value.CanAddr() && value.Addr().Type().Implements(nodeUnmarshalerType) {
+		unmarshaler, _ := value.Addr().Interface().(NodeUnmarshaler)
+		return unmarshaler.UnmarshalMarkdown(node, source)
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		decoded, err := decodeString(node, source)
+		if err != nil {
+			return err
+		}
+		value.SetString(decoded)
+		return nil
+
+	case reflect.Slice:
+		if node.Kind() == ast.KindFencedCodeBlock {
+			return unmarshalCode(node, source, value.Addr().Interface())
+		}
+		return decodeSlice(node, source, value)
+
+	case reflect.Struct, reflect.Map:
+		if value.Kind() == reflect.Struct && node.Kind() == ast.KindList {
+			return decodeListItemsInto(node, source, value)
+		}
+		return unmarshalCode(node, source, value.Addr().Interface())
+	}
+
+	return fmt.Errorf("unsupported field type %s", value.Type())
+}
+
+// decodeString decodes tags without their # prefix, wikilinks as their target, code blocks as their contents,
+// and any other node as text.
+func decodeString(node ast.Node, source []byte) (string, error) {
+	switch node.Kind() {
+	case hashtag.Kind:
+		return DecodeTag(node, source)
+	case mdwiki.Kind:
+		link, err := DecodeWikiLink(node, source)
+		return link.Target, err
+	case ast.KindFencedCodeBlock, ast.KindCodeBlock:
+		return DecodeCode(node, source)
+	}
+	return DecodeText(node, source)
+}
+
+// decodeSlice decodes a list into strings, or a table into maps or structs.
+func decodeSlice(node ast.Node, source []byte, value reflect.Value) error {
+	elem := value.Type().Elem()
+
+	switch {
+	case elem.Kind() == reflect.String:
+		items, err := DecodeListItems(node, source)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(items).Convert(value.Type()))
+		return nil
+
+	case elem == reflect.TypeOf(ListItem{}):
+		items, err := DecodeListTree(node, source)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(items))
+		return nil
+
+	case elem == reflect.TypeOf(map[string]string{}):
+		rows, err := DecodeTableToMap(node, source)
+		if err != nil {
+			return err
+		}
+		value.Set(reflect.ValueOf(rows))
+		return nil
+
+	case elem.Kind() == reflect.Struct:
+		return decodeTableInto(node, source, value)
+	}
+
+	return fmt.Errorf("unsupported field type %s", value.Type())
+}
+
+// columnName returns the table column header for a struct field, from its `larkdown` tag or name.
+func columnName(field reflect.StructField) (name string, ok bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+
+	if tag, ok := field.Tag.Lookup("larkdown"); ok {
+		return tag, tag != "-"
+	}
+	return field.Name, true
+}
+
+// UnmarshalError collects the errors for each field that failed to unmarshal.
+type UnmarshalError struct {
+	Errors []*FieldError
+}
+
+func (e *UnmarshalError) add(field string, err error) {
+	e.Errors = append(e.Errors, &FieldError{Field: field, Err: err})
+}
+
+func (e *UnmarshalError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("failed to unmarshal %d field(s): %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// Unwrap returns each field's error, for use with errors.Is and errors.As.
+func (e *UnmarshalError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// FieldError is an error for a single struct field.
+type FieldError struct {
+	Field string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s: %s", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,134 @@
+package larkdown_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"go.abhg.dev/goldmark/hashtag"
+
+	"github.com/will-wow/larkdown"
+	"github.com/will-wow/larkdown/internal/test"
+	"github.com/will-wow/larkdown/query"
+)
+
+type upperText string
+
+func (u *upperText) UnmarshalMarkdown(node ast.Node, source []byte) error {
+	*u = upperText(string(node.Text(source)) + "!")
+	return nil
+}
+
+func TestUnmarshal(t *testing.T) {
+	t.Run("decodes fields by type", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, recipeMarkdown, goldmark.WithExtensions(
+			extension.Table,
+			&hashtag.Extender{Variant: hashtag.ObsidianVariant},
+		))
+
+		type row struct {
+			Name string
+			Text string `larkdown:"Comment"`
+		}
+
+		var recipe struct {
+			Title       string              `larkdown:"[[# My Recipe]]"`
+			Shout       upperText           `larkdown:"[[# My Recipe]]"`
+			Tags        []string            `larkdown:"## Tags > [#tag],all"`
+			Ingredients []string            `larkdown:"# My Recipe > ## Ingredients > .list:first"`
+			Comments    []row               `larkdown:"## Comments > .table"`
+			Raw         []map[string]string `larkdown:"## Comments > .table"`
+			Missing     string              `larkdown:"## Missing,optional"`
+			Ignored     string
+		}
+
+		err := larkdown.Unmarshal(doc, source, &recipe)
+		require.NoError(t, err)
+
+		require.Equal(t, "My Recipe", recipe.Title)
+		require.Equal(t, upperText("My Recipe!"), recipe.Shout)
+		require.Equal(t, []string{"dinner", "chicken"}, recipe.Tags)
+		require.Equal(t, []string{"Chicken", "Vegetables", "Salt", "Pepper"}, recipe.Ingredients)
+		require.Equal(t, []row{{"Alice", "It's good!"}, {"Bob", "It's bad"}}, recipe.Comments)
+		require.Equal(t, "Bob", recipe.Raw[1]["Name"])
+		require.Equal(t, "", recipe.Missing)
+	})
+
+	t.Run("decodes nested lists", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `
+		## Outline
+
+		- Intro
+		  - Hook
+		- Body
+
+		## Details
+
+		- title: Dune
+		- rating: 5
+		`)
+
+		var notes struct {
+			Outline []larkdown.ListItem `larkdown:"## Outline > .list"`
+			Details struct {
+				Title  string
+				Rating int
+			} `larkdown:"## Details > .list"`
+		}
+
+		err := larkdown.Unmarshal(doc, source, &notes)
+		require.NoError(t, err)
+
+		require.Equal(t, []larkdown.ListItem{
+			{Text: "Intro", Children: []larkdown.ListItem{{Text: "Hook", Children: []larkdown.ListItem{}}}},
+			{Text: "Body", Children: []larkdown.ListItem{}},
+		}, notes.Outline)
+		require.Equal(t, "Dune", notes.Details.Title)
+		require.Equal(t, 5, notes.Details.Rating)
+	})
+
+	t.Run("aggregates missing fields", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `# Title`)
+
+		var out struct {
+			Title string   `larkdown:"# Title"`
+			Items []string `larkdown:"# Title > .list"`
+			Body  string   `larkdown:"## Body"`
+		}
+
+		err := larkdown.Unmarshal(doc, source, &out)
+
+		var unmarshalErr *larkdown.UnmarshalError
+		require.True(t, errors.As(err, &unmarshalErr))
+		require.Len(t, unmarshalErr.Errors, 2)
+		require.Equal(t, "Items", unmarshalErr.Errors[0].Field)
+		require.Equal(t, "Body", unmarshalErr.Errors[1].Field)
+
+		var queryErr *query.QueryError
+		require.True(t, errors.As(err, &queryErr), "unwraps to a QueryError")
+		require.ErrorContains(t, err, "field Body: failed to match query")
+	})
+
+	t.Run("reports bad tags and types", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `# Title`)
+
+		var out struct {
+			Bad    string `larkdown:"# Title > .lisst"`
+			Number int    `larkdown:"# Title"`
+		}
+
+		err := larkdown.Unmarshal(doc, source, &out)
+		require.ErrorContains(t, err, "field Bad: syntax error")
+		require.ErrorContains(t, err, "field Number: unsupported field type int")
+	})
+
+	t.Run("requires a struct pointer", func(t *testing.T) {
+		doc, source := test.TreeFromMd(t, `# Title`)
+
+		err := larkdown.Unmarshal(doc, source, struct{}{})
+		require.ErrorContains(t, err, "non-nil pointer to a struct")
+	})
+}